@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"html/template"
 	"log"
 	"net/http"
 	"os"
@@ -10,10 +11,17 @@ import (
 	"time"
 
 	"github.com/SergeiKhy/url-shortener/internal/config"
+	"github.com/SergeiKhy/url-shortener/internal/geoip"
 	"github.com/SergeiKhy/url-shortener/internal/handler"
+	"github.com/SergeiKhy/url-shortener/internal/logging"
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
 	"github.com/SergeiKhy/url-shortener/internal/middleware"
+	"github.com/SergeiKhy/url-shortener/internal/migrate"
+	"github.com/SergeiKhy/url-shortener/internal/models"
 	"github.com/SergeiKhy/url-shortener/internal/repository"
 	"github.com/SergeiKhy/url-shortener/internal/service"
+	"github.com/SergeiKhy/url-shortener/internal/tracing"
+	"github.com/SergeiKhy/url-shortener/migration"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -26,38 +34,167 @@ func main() {
 	}
 
 	// Инициализация логгера
-	logger, _ := zap.NewProduction()
+	logger, logLevel, err := logging.New(cfg.Log)
+	if err != nil {
+		log.Fatalf("Failed to init logger: %v", err)
+	}
 	defer logger.Sync()
 
+	// Подкоманда "migrate up|down|status"
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		code := runMigrate(cfg, logger, os.Args[2:])
+		logger.Sync()
+		os.Exit(code)
+	}
+
+	// Инициализация трейсинга (до подключений, чтобы инструментирование использовало провайдер)
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Fatal("Failed to init tracing", zap.Error(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Warn("Failed to flush traces", zap.Error(err))
+		}
+	}()
+	logger.Info("Tracing initialized", zap.String("exporter", cfg.Tracing.Exporter))
+
 	// Подключение к БД (postgres)
-	db, err := repository.NewPostgresDB(cfg.DB)
+	db, err := repository.NewPostgresDB(cfg.DB, logger)
 	if err != nil {
 		logger.Fatal("Failed to connect to database", zap.Error(err))
 	}
 	defer db.Close()
 	logger.Info("Connected to PostgreSQL")
 
-	// Подключение к Redis
+	// Автоматические миграции: advisory lock не даёт нескольким репликам применять их одновременно
+	if cfg.DB.AutoMigrate {
+		migrator, err := migrate.New(db.Pool, migration.FS, logger)
+		if err != nil {
+			logger.Fatal("Failed to load migrations", zap.Error(err))
+		}
+		if _, err := migrator.Up(context.Background()); err != nil {
+			logger.Fatal("Failed to apply migrations", zap.Error(err))
+		}
+	}
+
+	// Подключение к Redis (опционально: без него сервис работает без кэша)
 	redis, err := repository.NewRedisClient(cfg.Redis)
+	redisAvailable := err == nil
 	if err != nil {
-		logger.Fatal("Failed to connect to Redis", zap.Error(err))
+		if cfg.Redis.Required {
+			logger.Fatal("Failed to connect to Redis", zap.Error(err))
+		}
+		logger.Warn("Redis unavailable, starting without cache", zap.Error(err))
+		if redis, err = repository.NewLazyRedisClient(cfg.Redis); err != nil {
+			logger.Fatal("Failed to create Redis client", zap.Error(err))
+		}
+	} else {
+		logger.Info("Connected to Redis")
 	}
 	defer redis.Close()
-	logger.Info("Connected to Redis")
 
 	// Инициализация репозиториев
 	linkRepo := repository.NewLinkRepository(db)
-	cacheRepo := repository.NewCacheRepository(redis)
 	clickRepo := repository.NewClickRepository(db)
 
+	// Кэш за circuit breaker: при проблемах с Redis редиректы идут напрямую в БД,
+	// а фоновая проверка включает кэш обратно, когда Redis восстановится
+	breakerCache := repository.NewCircuitBreakerCache(
+		repository.NewCacheRepository(redis),
+		redis.Ping,
+		repository.CircuitBreakerConfig{
+			FailureThreshold: cfg.Redis.BreakerFailures,
+			CallTimeout:      cfg.Redis.BreakerCallTimeout,
+			ProbeInterval:    cfg.Redis.BreakerProbeInterval,
+		},
+		logger,
+	)
+	if !redisAvailable {
+		breakerCache.Trip()
+	}
+	defer breakerCache.Close()
+
+	// Локальный кэш самых популярных ссылок перед Redis, инвалидация через pub/sub
+	var cacheRepo repository.CacheRepository = breakerCache
+	if cfg.Cache.LocalSize > 0 {
+		localCache := repository.NewLocalCache(breakerCache, redis, repository.LocalCacheConfig{
+			Size:    cfg.Cache.LocalSize,
+			TTL:     cfg.Cache.LocalTTL,
+			Channel: cfg.Cache.InvalidationChannel,
+		}, logger)
+		localCache.Start()
+		defer localCache.Close()
+
+		metrics.MustRegister(metrics.NewLocalCacheCollector(localCache.Len))
+		cacheRepo = localCache
+	}
+
 	// Инициализация сервисов
-	linkService := service.NewLinkService(linkRepo, cacheRepo, logger)
+	if !models.ValidRedirectType(cfg.Links.DefaultRedirectType) {
+		logger.Fatal("Invalid default redirect type", zap.Int("redirect_type", cfg.Links.DefaultRedirectType))
+	}
+	linkService := service.NewLinkService(linkRepo, cacheRepo, service.LinkServiceConfig{
+		DefaultRedirectType: cfg.Links.DefaultRedirectType,
+	}, logger)
 
 	// Инициализация процессора кликов (Worker Pool)
 	clickProcessor := service.NewClickProcessor(clickRepo, linkRepo, logger)
 	clickProcessor.Start()
 	defer clickProcessor.Stop()
 
+	// Партиции кликов создаются заранее, старые отсоединяются по сроку хранения
+	partitionManager := service.NewPartitionManager(repository.NewClickPartitionRepository(db), service.PartitionManagerConfig{
+		Premake:         cfg.Clicks.PartitionPremake,
+		RetentionMonths: cfg.Clicks.RetentionMonths,
+		Archive:         cfg.Clicks.RetentionArchive,
+		Interval:        cfg.Clicks.PartitionInterval,
+	}, logger)
+	partitionManager.Start()
+	defer partitionManager.Stop()
+
+	// Очистка истёкших ссылок: advisory lock гарантирует, что её выполняет один инстанс
+	if cfg.Reaper.Enabled {
+		linkReaper := service.NewLinkReaper(linkRepo, cacheRepo, repository.NewAdvisoryLocker(db), service.LinkReaperConfig{
+			Interval:    cfg.Reaper.Interval,
+			GracePeriod: cfg.Reaper.GracePeriod,
+			BatchSize:   cfg.Reaper.BatchSize,
+			Archive:     cfg.Reaper.Archive,
+			ReuseCodes:  cfg.Reaper.ReuseCodes,
+		}, logger)
+		linkReaper.Start()
+		defer linkReaper.Stop()
+	}
+
+	// Прогрев кэша самыми популярными ссылками: после деплоя или очистки Redis
+	// первая волна трафика не уходит в PostgreSQL
+	cacheWarmer := service.NewCacheWarmer(linkRepo, cacheRepo, service.CacheWarmerConfig{
+		TopN:        cfg.Cache.WarmupTopN,
+		Window:      cfg.Cache.WarmupWindow,
+		Concurrency: cfg.Cache.WarmupConcurrency,
+	}, logger)
+	warmupCtx, cancelWarmup := context.WithCancel(context.Background())
+	defer cancelWarmup()
+	if cfg.Cache.WarmupOnStart {
+		go func() {
+			if _, err := cacheWarmer.Warmup(warmupCtx); err != nil && warmupCtx.Err() == nil {
+				logger.Warn("Cache warm-up failed", zap.Error(err))
+			}
+		}()
+	}
+
+	// Регистрация коллекторов метрик
+	for name, pool := range db.Pools() {
+		metrics.MustRegister(metrics.NewPostgresCollector(pool, name))
+	}
+	metrics.MustRegister(metrics.NewRedisCollector(redis.Client))
+	metrics.MustRegister(metrics.NewClickQueueCollector(func() (int, int) {
+		stats := clickProcessor.GetChannelStats()
+		return stats.BufferUsed, stats.BufferSize
+	})...)
+
 	// Инициализация middleware
 	rateLimiter := middleware.NewRateLimiter(middleware.RateLimiterConfig{
 		RequestsPerSecond: cfg.RateLimit.RequestsPerSecond,
@@ -71,8 +208,55 @@ func main() {
 		logger.Info("API key authentication enabled", zap.Int("keys_count", len(cfg.Auth.APIKeys)))
 	}
 
+	// Liveness/readiness probe
+	health := handler.NewHealthHandler(cfg.Health.CheckTimeout, logger,
+		handler.ReadinessCheck{Name: "postgres", Check: db.Ping},
+		handler.ReadinessCheck{Name: "redis", Check: redis.Ping, Optional: !cfg.Redis.Required},
+		handler.ClickBacklogCheck(clickProcessor, cfg.Health.MaxClickBacklog),
+	)
+
 	// Настройка роутера
-	router := handler.NewRouter(linkService, clickProcessor, rateLimiter, apiKeyMiddleware, logger)
+	domains, err := handler.NewDomains(cfg.App.BaseURL, cfg.App.Domains)
+	if err != nil {
+		logger.Fatal("Invalid domain configuration", zap.Error(err))
+	}
+	logger.Info("Short link domains", zap.Strings("domains", domains.Names()))
+	linkConfig := handler.LinkHandlerConfig{
+		PasswordMaxAttempts:   cfg.Links.PasswordMaxAttempts,
+		PasswordAttemptWindow: cfg.Links.PasswordAttemptWindow,
+		NotActiveURL:          cfg.Links.NotActiveURL,
+	}
+	// Пустой путь оставляет встроенную страницу
+	for _, page := range []struct {
+		path string
+		page **template.Template
+	}{
+		{cfg.Links.NotActiveTemplate, &linkConfig.NotActivePage},
+		{cfg.Links.NotFoundTemplate, &linkConfig.NotFoundPage},
+		{cfg.Links.ExpiredTemplate, &linkConfig.ExpiredPage},
+		{cfg.Links.PreviewTemplate, &linkConfig.PreviewPage},
+		{cfg.Links.AppTemplate, &linkConfig.AppPage},
+	} {
+		if page.path == "" {
+			continue
+		}
+		if *page.page, err = handler.ParsePageTemplate(page.path); err != nil {
+			logger.Fatal("Invalid link page template", zap.Error(err))
+		}
+	}
+	if cfg.GeoIP.DatabasePath != "" {
+		geoResolver, err := geoip.Open(cfg.GeoIP.DatabasePath)
+		if err != nil {
+			logger.Fatal("Failed to open GeoIP database", zap.Error(err))
+		}
+		defer geoResolver.Close()
+		linkConfig.GeoIP = geoResolver
+		logger.Info("GeoIP database loaded", zap.String("path", cfg.GeoIP.DatabasePath))
+	}
+	router, err := handler.NewRouter(linkService, clickProcessor, rateLimiter, apiKeyMiddleware, health, domains, cfg.App.TrustedProxies, linkConfig, logger)
+	if err != nil {
+		logger.Fatal("Failed to configure router", zap.Error(err))
+	}
 
 	// Запуск сервера
 	srv := &http.Server{
@@ -91,11 +275,32 @@ func main() {
 		}
 	}()
 
+	// Служебный сервер (метрики) на отдельном порту
+	adminSrv := &http.Server{
+		Addr:         ":" + cfg.Admin.Port,
+		Handler:      handler.NewAdminRouter(logLevel, cacheWarmer),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 2 * time.Minute, // прогрев кэша отвечает после загрузки всех ссылок
+	}
+
+	go func() {
+		logger.Info("Admin server starting", zap.String("port", cfg.Admin.Port))
+		if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Failed to start admin server", zap.Error(err))
+		}
+	}()
+
 	// Graceful Shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// Сначала выводим инстанс из балансировки, затем останавливаем сервер
+	cancelWarmup()
+	health.SetDraining()
+	logger.Info("Draining before shutdown", zap.Duration("delay", cfg.Health.ShutdownDrainDelay))
+	time.Sleep(cfg.Health.ShutdownDrainDelay)
+
 	logger.Info("Shutting down server...")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -104,5 +309,9 @@ func main() {
 		logger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
 
+	if err := adminSrv.Shutdown(ctx); err != nil {
+		logger.Error("Admin server forced to shutdown", zap.Error(err))
+	}
+
 	logger.Info("Server exited")
 }
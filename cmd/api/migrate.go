@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/SergeiKhy/url-shortener/internal/config"
+	"github.com/SergeiKhy/url-shortener/internal/migrate"
+	"github.com/SergeiKhy/url-shortener/internal/repository"
+	"github.com/SergeiKhy/url-shortener/migration"
+	"go.uber.org/zap"
+)
+
+const migrateUsage = `Usage: api migrate <command>
+
+Commands:
+  up        apply all pending migrations
+  down [N]  roll back the last N migrations (default 1)
+  status    show applied and pending migrations`
+
+// runMigrate выполняет подкоманду "api migrate" и возвращает код выхода
+func runMigrate(cfg *config.Config, logger *zap.Logger, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	// Миграции выполняются только на primary
+	dbConfig := cfg.DB
+	dbConfig.ReplicaDSNs = nil
+	db, err := repository.NewPostgresDB(dbConfig, logger)
+	if err != nil {
+		logger.Error("Failed to connect to database", zap.Error(err))
+		return 1
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db.Pool, migration.FS, logger)
+	if err != nil {
+		logger.Error("Failed to load migrations", zap.Error(err))
+		return 1
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			logger.Error("Migration failed", zap.Error(err))
+			return 1
+		}
+		fmt.Printf("Applied %d migration(s)\n", len(applied))
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			logger.Error("Rollback failed", zap.Error(err))
+			return 1
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", len(reverted))
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Error("Failed to get migration status", zap.Error(err))
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%06d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	return 0
+}
@@ -2,20 +2,42 @@ package config
 
 import (
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
 	App       AppConfig
+	Admin     AdminConfig
 	DB        DBConfig
 	Redis     RedisConfig
+	Cache     CacheConfig
+	Clicks    ClicksConfig
+	Links     LinksConfig
+	GeoIP     GeoIPConfig
+	Reaper    ReaperConfig
 	Auth      AuthConfig
 	RateLimit RateLimitConfig
+	Tracing   TracingConfig
+	Log       LogConfig
+	Health    HealthConfig
 }
 
 type AppConfig struct {
 	Port string
+	// BaseURL публичный адрес сервиса для коротких ссылок домена по умолчанию
+	BaseURL string
+	// Domains дополнительные домены коротких ссылок ("go.example.com" или "https://go.example.com")
+	Domains []string
+	// TrustedProxies IP адреса и подсети прокси, которым разрешено передавать адрес клиента
+	// в X-Forwarded-For. Пусто — заголовок игнорируется, адрес клиента берётся из соединения.
+	TrustedProxies []string
+}
+
+// AdminConfig служебный HTTP сервер (метрики, администрирование)
+type AdminConfig struct {
+	Port string
 }
 
 type DBConfig struct {
@@ -24,11 +46,142 @@ type DBConfig struct {
 	User     string
 	Password string
 	Name     string
+	// TLS: sslmode (disable, require, verify-ca, verify-full) и CA для проверки сервера
+	SSLMode     string
+	SSLRootCert string
+	// Пул соединений (0 — значение по умолчанию)
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	// ReplicaDSNs DSN реплик для чтения (редиректы, статистика); пусто — всё читается с primary
+	ReplicaDSNs []string
+	// ReplicaCheckInterval интервал проверки доступности реплик
+	ReplicaCheckInterval time.Duration
+	// AutoMigrate применять миграции при старте
+	AutoMigrate bool
 }
 
 type RedisConfig struct {
+	// Mode топология: standalone (Host:Port), sentinel или cluster (Addrs)
+	Mode string
 	Host string
 	Port string
+	// Addrs адреса sentinel или узлов кластера
+	Addrs []string
+	// MasterName имя master в Sentinel
+	MasterName       string
+	SentinelUsername string
+	SentinelPassword string
+	// Username пользователь ACL (Redis 6+), пустой — пользователь default
+	Username string
+	Password string
+	DB       int // в режиме cluster поддерживается только 0
+	// TLS
+	TLSEnabled    bool
+	TLSCAFile     string // PEM с CA для проверки сертификата сервера, по умолчанию системные CA
+	TLSServerName string // имя для проверки сертификата, если отличается от адреса
+	// Required если false, сервис стартует и работает без Redis (без кэша)
+	Required bool
+	// Circuit breaker вокруг кэша
+	BreakerFailures      int
+	BreakerCallTimeout   time.Duration
+	BreakerProbeInterval time.Duration
+}
+
+// CacheConfig настройки локального (in-process) кэша ссылок перед Redis
+type CacheConfig struct {
+	LocalSize int           // максимум ссылок в памяти, 0 отключает локальный кэш
+	LocalTTL  time.Duration // время жизни записи в локальном кэше
+	// InvalidationChannel канал Redis pub/sub для инвалидации локальных кэшей всех инстансов
+	InvalidationChannel string
+	// Прогрев кэша самыми популярными ссылками при старте и через служебный эндпоинт
+	WarmupOnStart     bool
+	WarmupTopN        int
+	WarmupWindow      time.Duration // период, за который считаются клики
+	WarmupConcurrency int
+}
+
+// ClicksConfig обслуживание помесячных партиций таблицы кликов
+type ClicksConfig struct {
+	PartitionPremake  int           // на сколько месяцев вперёд создавать партиции
+	PartitionInterval time.Duration // интервал фоновой проверки партиций
+	// RetentionMonths сколько полных месяцев хранить клики кроме текущего, 0 — бессрочно
+	RetentionMonths int
+	// RetentionArchive переносить старые партиции в схему clicks_archive вместо удаления
+	RetentionArchive bool
+}
+
+// LinksConfig настройки ссылок по умолчанию
+type LinksConfig struct {
+	// DefaultRedirectType HTTP статус редиректа новых ссылок без явного redirect_type
+	DefaultRedirectType int
+	// Неудачные попытки ввода пароля ссылки с одного IP: не больше PasswordMaxAttempts
+	// за PasswordAttemptWindow
+	PasswordMaxAttempts   int
+	PasswordAttemptWindow time.Duration
+	// До времени активации ссылки: редирект на NotActiveURL или страница из
+	// HTML шаблона NotActiveTemplate (пусто — встроенная страница)
+	NotActiveURL      string
+	NotActiveTemplate string
+	// HTML шаблоны страниц несуществующей и истёкшей ссылки (пусто — встроенные)
+	NotFoundTemplate string
+	ExpiredTemplate  string
+	// PreviewTemplate HTML шаблон страницы предпросмотра (пусто — встроенная)
+	PreviewTemplate string
+	// AppTemplate HTML шаблон страницы открытия приложения по deep link (пусто — встроенная)
+	AppTemplate string
+}
+
+// ReaperConfig фоновая очистка истёкших ссылок
+type ReaperConfig struct {
+	Enabled     bool
+	Interval    time.Duration
+	GracePeriod time.Duration // сколько истёкшая ссылка хранится до удаления
+	BatchSize   int           // ссылок в одной транзакции
+	Archive     bool          // переносить в links_archive вместо удаления
+	ReuseCodes  bool          // разрешить повторную выдачу кодов удалённых ссылок
+}
+
+// GeoIPConfig локальная база GeoIP для правил геотаргетинга и страны в статистике кликов
+type GeoIPConfig struct {
+	// DatabasePath путь к базе MaxMind (.mmdb), пустой — страна не определяется
+	DatabasePath string
+}
+
+// HealthConfig настройки liveness/readiness probe
+type HealthConfig struct {
+	CheckTimeout time.Duration // таймаут проверки одной зависимости
+	// MaxClickBacklog доля заполнения канала кликов, при которой инстанс считается неготовым
+	MaxClickBacklog float64
+	// ShutdownDrainDelay сколько readiness отвечает 503 перед остановкой сервера
+	ShutdownDrainDelay time.Duration
+}
+
+// LogConfig настройки логгера
+type LogConfig struct {
+	Level  string // debug, info, warn, error
+	Format string // json, console
+	// Сэмплирование логов редиректа: первые SamplingInitial одинаковых записей в секунду,
+	// затем каждая SamplingThereafter-я. 0 отключает сэмплирование.
+	SamplingInitial    int
+	SamplingThereafter int
+	// Файл с ротацией вместо stdout (опционально)
+	File           string
+	FileMaxSizeMB  int
+	FileMaxBackups int
+	FileMaxAgeDays int
+}
+
+// TracingConfig настройки OpenTelemetry
+type TracingConfig struct {
+	Exporter     string // none, otlp, stdout, file
+	ServiceName  string
+	OTLPEndpoint string // host:port OTLP gRPC коллектора
+	OTLPInsecure bool
+	FilePath     string // файл для экспортёра file
+	SampleRatio  float64
 }
 
 type AuthConfig struct {
@@ -50,13 +203,133 @@ func Load() (*Config, error) {
 
 	var cfg Config
 	cfg.App.Port = viper.GetString("APP_PORT")
+	cfg.App.BaseURL = viper.GetString("APP_BASE_URL")
+	if cfg.App.BaseURL == "" {
+		cfg.App.BaseURL = "http://localhost:" + cfg.App.Port
+	}
+	cfg.App.Domains = parseList(viper.GetString("APP_DOMAINS"))
+	cfg.App.TrustedProxies = parseList(viper.GetString("TRUSTED_PROXIES"))
+	cfg.Admin.Port = viper.GetString("ADMIN_PORT")
+	if cfg.Admin.Port == "" {
+		cfg.Admin.Port = "9090"
+	}
 	cfg.DB.Host = viper.GetString("DB_HOST")
 	cfg.DB.Port = viper.GetString("DB_PORT")
 	cfg.DB.User = viper.GetString("DB_USER")
 	cfg.DB.Password = viper.GetString("DB_PASSWORD")
 	cfg.DB.Name = viper.GetString("DB_NAME")
+	cfg.DB.SSLMode = viper.GetString("DB_SSLMODE")
+	cfg.DB.SSLRootCert = viper.GetString("DB_SSLROOTCERT")
+	cfg.DB.MaxConns = viper.GetInt32("DB_MAX_CONNS")
+	cfg.DB.MinConns = viper.GetInt32("DB_MIN_CONNS")
+	cfg.DB.MaxConnLifetime = viper.GetDuration("DB_MAX_CONN_LIFETIME")
+	cfg.DB.MaxConnIdleTime = viper.GetDuration("DB_MAX_CONN_IDLE_TIME")
+	cfg.DB.HealthCheckPeriod = viper.GetDuration("DB_HEALTH_CHECK_PERIOD")
+	cfg.DB.ReplicaDSNs = parseList(viper.GetString("DB_REPLICA_DSNS"))
+	cfg.DB.ReplicaCheckInterval = viper.GetDuration("DB_REPLICA_CHECK_INTERVAL")
+	cfg.DB.AutoMigrate = viper.GetBool("DB_AUTO_MIGRATE")
+	cfg.Redis.Mode = viper.GetString("REDIS_MODE")
+	if cfg.Redis.Mode == "" {
+		cfg.Redis.Mode = "standalone"
+	}
 	cfg.Redis.Host = viper.GetString("REDIS_HOST")
 	cfg.Redis.Port = viper.GetString("REDIS_PORT")
+	cfg.Redis.Addrs = parseList(viper.GetString("REDIS_ADDRS"))
+	cfg.Redis.MasterName = viper.GetString("REDIS_SENTINEL_MASTER")
+	cfg.Redis.SentinelUsername = viper.GetString("REDIS_SENTINEL_USERNAME")
+	cfg.Redis.SentinelPassword = viper.GetString("REDIS_SENTINEL_PASSWORD")
+	cfg.Redis.Username = viper.GetString("REDIS_USERNAME")
+	cfg.Redis.Password = viper.GetString("REDIS_PASSWORD")
+	cfg.Redis.DB = viper.GetInt("REDIS_DB")
+	cfg.Redis.TLSEnabled = viper.GetBool("REDIS_TLS_ENABLED")
+	cfg.Redis.TLSCAFile = viper.GetString("REDIS_TLS_CA_FILE")
+	cfg.Redis.TLSServerName = viper.GetString("REDIS_TLS_SERVER_NAME")
+	cfg.Redis.Required = viper.GetBool("REDIS_REQUIRED")
+	cfg.Redis.BreakerFailures = viper.GetInt("REDIS_BREAKER_FAILURES")
+	cfg.Redis.BreakerCallTimeout = viper.GetDuration("REDIS_BREAKER_CALL_TIMEOUT")
+	cfg.Redis.BreakerProbeInterval = viper.GetDuration("REDIS_BREAKER_PROBE_INTERVAL")
+
+	// Local cache config
+	cfg.Cache.LocalSize = 10000
+	if viper.IsSet("LOCAL_CACHE_SIZE") {
+		cfg.Cache.LocalSize = viper.GetInt("LOCAL_CACHE_SIZE")
+	}
+	cfg.Cache.LocalTTL = viper.GetDuration("LOCAL_CACHE_TTL")
+	if cfg.Cache.LocalTTL == 0 {
+		cfg.Cache.LocalTTL = 30 * time.Second
+	}
+	cfg.Cache.InvalidationChannel = viper.GetString("CACHE_INVALIDATION_CHANNEL")
+	if cfg.Cache.InvalidationChannel == "" {
+		cfg.Cache.InvalidationChannel = "url-shortener:cache-invalidation"
+	}
+	cfg.Cache.WarmupOnStart = true
+	if viper.IsSet("CACHE_WARMUP_ON_START") {
+		cfg.Cache.WarmupOnStart = viper.GetBool("CACHE_WARMUP_ON_START")
+	}
+	cfg.Cache.WarmupTopN = viper.GetInt("CACHE_WARMUP_TOP_N")
+	if cfg.Cache.WarmupTopN == 0 {
+		cfg.Cache.WarmupTopN = 1000
+	}
+	cfg.Cache.WarmupWindow = viper.GetDuration("CACHE_WARMUP_WINDOW")
+	if cfg.Cache.WarmupWindow == 0 {
+		cfg.Cache.WarmupWindow = 24 * time.Hour
+	}
+	cfg.Cache.WarmupConcurrency = viper.GetInt("CACHE_WARMUP_CONCURRENCY")
+	if cfg.Cache.WarmupConcurrency == 0 {
+		cfg.Cache.WarmupConcurrency = 8
+	}
+
+	// Clicks partitions config
+	cfg.Clicks.PartitionPremake = viper.GetInt("CLICKS_PARTITION_PREMAKE")
+	if cfg.Clicks.PartitionPremake == 0 {
+		cfg.Clicks.PartitionPremake = 3
+	}
+	cfg.Clicks.PartitionInterval = viper.GetDuration("CLICKS_PARTITION_CHECK_INTERVAL")
+	if cfg.Clicks.PartitionInterval == 0 {
+		cfg.Clicks.PartitionInterval = time.Hour
+	}
+	cfg.Clicks.RetentionMonths = viper.GetInt("CLICKS_RETENTION_MONTHS")
+	cfg.Clicks.RetentionArchive = viper.GetBool("CLICKS_RETENTION_ARCHIVE")
+
+	// Links config
+	cfg.Links.DefaultRedirectType = viper.GetInt("LINK_DEFAULT_REDIRECT_TYPE")
+	if cfg.Links.DefaultRedirectType == 0 {
+		cfg.Links.DefaultRedirectType = 307
+	}
+	cfg.Links.PasswordMaxAttempts = viper.GetInt("LINK_PASSWORD_MAX_ATTEMPTS")
+	if cfg.Links.PasswordMaxAttempts == 0 {
+		cfg.Links.PasswordMaxAttempts = 5
+	}
+	cfg.Links.PasswordAttemptWindow = viper.GetDuration("LINK_PASSWORD_ATTEMPT_WINDOW")
+	if cfg.Links.PasswordAttemptWindow == 0 {
+		cfg.Links.PasswordAttemptWindow = 15 * time.Minute
+	}
+	cfg.Links.NotActiveURL = viper.GetString("LINK_NOT_ACTIVE_URL")
+	cfg.Links.NotActiveTemplate = viper.GetString("LINK_NOT_ACTIVE_TEMPLATE")
+	cfg.Links.NotFoundTemplate = viper.GetString("LINK_NOT_FOUND_TEMPLATE")
+	cfg.Links.ExpiredTemplate = viper.GetString("LINK_EXPIRED_TEMPLATE")
+	cfg.Links.PreviewTemplate = viper.GetString("LINK_PREVIEW_TEMPLATE")
+	cfg.Links.AppTemplate = viper.GetString("LINK_APP_TEMPLATE")
+
+	// Expired links reaper config
+	cfg.Reaper.Enabled = true
+	if viper.IsSet("LINK_REAPER_ENABLED") {
+		cfg.Reaper.Enabled = viper.GetBool("LINK_REAPER_ENABLED")
+	}
+	cfg.Reaper.Interval = viper.GetDuration("LINK_REAPER_INTERVAL")
+	if cfg.Reaper.Interval == 0 {
+		cfg.Reaper.Interval = 10 * time.Minute
+	}
+	cfg.Reaper.GracePeriod = viper.GetDuration("LINK_REAPER_GRACE_PERIOD")
+	if cfg.Reaper.GracePeriod == 0 {
+		cfg.Reaper.GracePeriod = 24 * time.Hour
+	}
+	cfg.Reaper.BatchSize = viper.GetInt("LINK_REAPER_BATCH_SIZE")
+	if cfg.Reaper.BatchSize == 0 {
+		cfg.Reaper.BatchSize = 500
+	}
+	cfg.Reaper.Archive = viper.GetBool("LINK_REAPER_ARCHIVE")
+	cfg.Reaper.ReuseCodes = viper.GetBool("LINK_REAPER_REUSE_CODES")
 
 	// Auth config - parse API keys from comma-separated string
 	// Format: key1:name1,key2:name2
@@ -73,9 +346,87 @@ func Load() (*Config, error) {
 		cfg.RateLimit.BurstSize = 20
 	}
 
+	// Health config
+	cfg.GeoIP.DatabasePath = viper.GetString("GEOIP_DATABASE_PATH")
+
+	cfg.Health.CheckTimeout = viper.GetDuration("HEALTH_CHECK_TIMEOUT")
+	if cfg.Health.CheckTimeout == 0 {
+		cfg.Health.CheckTimeout = 2 * time.Second
+	}
+	cfg.Health.MaxClickBacklog = viper.GetFloat64("HEALTH_MAX_CLICK_BACKLOG")
+	if cfg.Health.MaxClickBacklog == 0 {
+		cfg.Health.MaxClickBacklog = 0.9
+	}
+	cfg.Health.ShutdownDrainDelay = 5 * time.Second
+	if viper.IsSet("SHUTDOWN_DRAIN_DELAY") {
+		cfg.Health.ShutdownDrainDelay = viper.GetDuration("SHUTDOWN_DRAIN_DELAY")
+	}
+
+	// Log config
+	cfg.Log.Level = viper.GetString("LOG_LEVEL")
+	if cfg.Log.Level == "" {
+		cfg.Log.Level = "info"
+	}
+	cfg.Log.Format = viper.GetString("LOG_FORMAT")
+	if cfg.Log.Format == "" {
+		cfg.Log.Format = "json"
+	}
+	cfg.Log.SamplingInitial = 100
+	if viper.IsSet("LOG_SAMPLING_INITIAL") {
+		cfg.Log.SamplingInitial = viper.GetInt("LOG_SAMPLING_INITIAL")
+	}
+	cfg.Log.SamplingThereafter = viper.GetInt("LOG_SAMPLING_THEREAFTER")
+	if cfg.Log.SamplingThereafter == 0 {
+		cfg.Log.SamplingThereafter = 100
+	}
+	cfg.Log.File = viper.GetString("LOG_FILE")
+	cfg.Log.FileMaxSizeMB = viper.GetInt("LOG_FILE_MAX_SIZE_MB")
+	if cfg.Log.FileMaxSizeMB == 0 {
+		cfg.Log.FileMaxSizeMB = 100
+	}
+	cfg.Log.FileMaxBackups = viper.GetInt("LOG_FILE_MAX_BACKUPS")
+	if cfg.Log.FileMaxBackups == 0 {
+		cfg.Log.FileMaxBackups = 5
+	}
+	cfg.Log.FileMaxAgeDays = viper.GetInt("LOG_FILE_MAX_AGE_DAYS")
+	if cfg.Log.FileMaxAgeDays == 0 {
+		cfg.Log.FileMaxAgeDays = 30
+	}
+
+	// Tracing config
+	cfg.Tracing.Exporter = viper.GetString("TRACING_EXPORTER")
+	if cfg.Tracing.Exporter == "" {
+		cfg.Tracing.Exporter = "none"
+	}
+	cfg.Tracing.ServiceName = viper.GetString("TRACING_SERVICE_NAME")
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = "url-shortener"
+	}
+	cfg.Tracing.OTLPEndpoint = viper.GetString("TRACING_OTLP_ENDPOINT")
+	cfg.Tracing.OTLPInsecure = viper.GetBool("TRACING_OTLP_INSECURE")
+	cfg.Tracing.FilePath = viper.GetString("TRACING_FILE")
+	if cfg.Tracing.FilePath == "" {
+		cfg.Tracing.FilePath = "traces.json"
+	}
+	cfg.Tracing.SampleRatio = 1
+	if viper.IsSet("TRACING_SAMPLE_RATIO") {
+		cfg.Tracing.SampleRatio = viper.GetFloat64("TRACING_SAMPLE_RATIO")
+	}
+
 	return &cfg, nil
 }
 
+// parseList parses comma-separated values, skipping empty items
+func parseList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // parseAPIKeys parses comma-separated API keys in format "key1:name1,key2:name2"
 func parseAPIKeys(raw string) map[string]string {
 	keys := make(map[string]string)
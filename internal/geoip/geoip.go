@@ -0,0 +1,55 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Resolver определяет страну посетителя по IP адресу
+type Resolver interface {
+	// Country возвращает ISO 3166-1 alpha-2 код страны в верхнем регистре
+	// или пустую строку, если страна не определена
+	Country(ip string) string
+}
+
+// MaxMindResolver читает локальную базу MaxMind (GeoLite2/GeoIP2 Country или City, формат .mmdb).
+// База открывается через mmap, поиск не обращается к сети и безопасен для параллельного вызова.
+type MaxMindResolver struct {
+	db *maxminddb.Reader
+}
+
+// countryRecord поля записи базы, нужные для определения страны
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// Open открывает базу MaxMind по пути к файлу .mmdb
+func Open(path string) (*MaxMindResolver, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database %s: %w", path, err)
+	}
+	return &MaxMindResolver{db: db}, nil
+}
+
+func (r *MaxMindResolver) Country(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+	var record countryRecord
+	if err := r.db.Lookup(addr, &record); err != nil {
+		return ""
+	}
+	return strings.ToUpper(record.Country.ISOCode)
+}
+
+// Close освобождает mmap базы
+func (r *MaxMindResolver) Close() error {
+	return r.db.Close()
+}
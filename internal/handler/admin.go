@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
+	"github.com/SergeiKhy/url-shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// NewAdminRouter создаёт роутер служебного сервера, который слушает отдельный порт
+// и не должен быть доступен извне
+func NewAdminRouter(logLevel zap.AtomicLevel, cacheWarmer service.CacheWarmer) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Уровень логирования: GET возвращает текущий, PUT {"level":"debug"} меняет его без рестарта
+	router.GET("/log/level", gin.WrapH(logLevel))
+	router.PUT("/log/level", gin.WrapH(logLevel))
+
+	// Прогрев кэша самыми популярными ссылками (например, после очистки Redis)
+	router.POST("/cache/warmup", warmupCache(cacheWarmer))
+
+	return router
+}
+
+// warmupCache запускает прогрев кэша и возвращает его итог
+func warmupCache(cacheWarmer service.CacheWarmer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := cacheWarmer.Warmup(c.Request.Context())
+		if err != nil {
+			if errors.Is(err, service.ErrWarmupInProgress) {
+				c.JSON(http.StatusConflict, ErrorResponse{
+					Error:   "warmup_in_progress",
+					Message: "Cache warm-up is already running",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to warm up cache",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
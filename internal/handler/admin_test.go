@@ -0,0 +1,48 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SergeiKhy/url-shortener/internal/handler"
+	"github.com/SergeiKhy/url-shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubWarmer прогрев кэша с заранее заданным результатом
+type stubWarmer struct {
+	result *service.WarmupResult
+	err    error
+}
+
+func (s *stubWarmer) Warmup(ctx context.Context) (*service.WarmupResult, error) {
+	return s.result, s.err
+}
+
+// TestAdminRouter_CacheWarmup проверяет ответы эндпоинта прогрева кэша
+func TestAdminRouter_CacheWarmup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(warmer service.CacheWarmer) *httptest.ResponseRecorder {
+		router := handler.NewAdminRouter(zap.NewAtomicLevel(), warmer)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/cache/warmup", nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(&stubWarmer{result: &service.WarmupResult{Total: 3, Loaded: 2, Failed: 1}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result service.WarmupResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 2, result.Loaded)
+
+	w = serve(&stubWarmer{err: service.ErrWarmupInProgress})
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Domains публичные домены коротких ссылок: домен по умолчанию из базового URL
+// и дополнительные брендированные домены
+type Domains struct {
+	baseURL     string            // базовый URL домена по умолчанию без завершающего «/»
+	defaultHost string            // хост домена по умолчанию
+	custom      map[string]string // хост -> базовый URL
+}
+
+// NewDomains разбирает базовый URL и список дополнительных доменов. Домен без схемы
+// наследует схему базового URL: "go.example.com" или "https://go.example.com".
+func NewDomains(baseURL string, custom []string) (*Domains, error) {
+	base, err := parseBaseURL(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+	}
+
+	d := &Domains{
+		baseURL:     strings.TrimSuffix(base.String(), "/"),
+		defaultHost: normalizeHost(base.Host),
+		custom:      make(map[string]string, len(custom)),
+	}
+
+	for _, entry := range custom {
+		if !strings.Contains(entry, "://") {
+			entry = base.Scheme + "://" + entry
+		}
+		u, err := parseBaseURL(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid domain %q: %w", entry, err)
+		}
+		host := normalizeHost(u.Host)
+		if host == d.defaultHost {
+			continue
+		}
+		d.custom[host] = strings.TrimSuffix(u.String(), "/")
+	}
+
+	return d, nil
+}
+
+// Resolve возвращает домен ссылки по заголовку Host. Домен по умолчанию и
+// неизвестные хосты (IP балансировщика, внутренние имена) дают пустой домен.
+func (d *Domains) Resolve(host string) string {
+	host = normalizeHost(host)
+	if _, ok := d.custom[host]; ok {
+		return host
+	}
+	return ""
+}
+
+// Lookup проверяет домен из запроса API. Пустой домен и домен по умолчанию дают "".
+func (d *Domains) Lookup(domain string) (string, bool) {
+	host := normalizeHost(domain)
+	if host == "" || host == d.defaultHost {
+		return "", true
+	}
+	if _, ok := d.custom[host]; ok {
+		return host, true
+	}
+	return "", false
+}
+
+// ShortURL публичный адрес короткой ссылки
+func (d *Domains) ShortURL(domain, code string) string {
+	if base, ok := d.custom[domain]; ok {
+		return base + "/" + code
+	}
+	return d.baseURL + "/" + code
+}
+
+// Names хосты всех доменов, начиная с домена по умолчанию
+func (d *Domains) Names() []string {
+	names := make([]string, 0, len(d.custom)+1)
+	for host := range d.custom {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+	return append([]string{d.defaultHost}, names...)
+}
+
+func parseBaseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+	return u, nil
+}
+
+// normalizeHost приводит хост к виду без порта, в нижнем регистре и без завершающей точки
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,41 @@
+package handler_test
+
+import (
+	"testing"
+
+	"github.com/SergeiKhy/url-shortener/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDomains проверяет короткие адреса и определение домена по Host
+func TestDomains(t *testing.T) {
+	domains, err := handler.NewDomains("https://sho.rt/", []string{"go.example.com", "http://promo.example.com:8080"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://sho.rt/abc123", domains.ShortURL("", "abc123"))
+	assert.Equal(t, "https://go.example.com/abc123", domains.ShortURL("go.example.com", "abc123"))
+	assert.Equal(t, "http://promo.example.com:8080/abc123", domains.ShortURL("promo.example.com", "abc123"))
+	assert.Equal(t, []string{"sho.rt", "go.example.com", "promo.example.com"}, domains.Names())
+
+	assert.Equal(t, "go.example.com", domains.Resolve("Go.Example.com:443"))
+	assert.Equal(t, "", domains.Resolve("sho.rt"))
+	assert.Equal(t, "", domains.Resolve("10.0.0.1:8080"), "неизвестный хост — домен по умолчанию")
+
+	for input, want := range map[string]string{"": "", "sho.rt": "", "GO.example.com": "go.example.com"} {
+		domain, ok := domains.Lookup(input)
+		assert.True(t, ok, input)
+		assert.Equal(t, want, domain, input)
+	}
+	_, ok := domains.Lookup("evil.example.com")
+	assert.False(t, ok)
+}
+
+// TestNewDomains_Invalid проверяет отказ на некорректных адресах
+func TestNewDomains_Invalid(t *testing.T) {
+	_, err := handler.NewDomains("localhost:8080", nil)
+	assert.Error(t, err, "базовый URL без схемы")
+
+	_, err = handler.NewDomains("http://localhost:8080", []string{"ftp://files.example.com"})
+	assert.Error(t, err)
+}
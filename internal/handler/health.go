@@ -1,9 +1,17 @@
 package handler
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/SergeiKhy/url-shortener/internal/service"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
 func HealthCheck(c *gin.Context) {
@@ -12,3 +20,174 @@ func HealthCheck(c *gin.Context) {
 		"service": "url-shortener",
 	})
 }
+
+// Статусы readiness probe
+const (
+	statusOK          = "ok"
+	statusDegraded    = "degraded"
+	statusUnavailable = "unavailable"
+	statusDraining    = "draining"
+	componentUp       = "up"
+	componentDown     = "down"
+)
+
+// ReadinessCheck проверка одной зависимости для readiness probe
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// Optional сервис работает без этой зависимости (в деградированном режиме),
+	// её недоступность не снимает инстанс с балансировки
+	Optional bool
+}
+
+// ComponentStatus результат проверки одной зависимости. Текст ошибки в ответ не попадает:
+// probe доступен снаружи, а ошибки драйверов раскрывают адреса и устройство инфраструктуры.
+// Ошибка пишется в лог.
+type ComponentStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Optional  bool    `json:"optional,omitempty"`
+}
+
+// ReadinessResponse ответ readiness probe
+type ReadinessResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// HealthHandler обработчики liveness и readiness probe
+type HealthHandler struct {
+	checks   []ReadinessCheck
+	timeout  time.Duration
+	logger   *zap.Logger
+	draining atomic.Bool
+	// checking объединяет одновременные запросы readiness в один прогон проверок
+	checking singleflight.Group
+}
+
+// NewHealthHandler создаёт обработчик probe с таймаутом на каждую проверку
+func NewHealthHandler(timeout time.Duration, logger *zap.Logger, checks ...ReadinessCheck) *HealthHandler {
+	return &HealthHandler{
+		checks:  checks,
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// SetDraining переводит инстанс в режим вывода из балансировки: readiness начинает отвечать 503
+func (h *HealthHandler) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Livez godoc
+// @Summary Liveness probe
+// @Description Process is alive; does not check dependencies
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /livez [get]
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": statusOK})
+}
+
+// Readyz godoc
+// @Summary Readiness probe
+// @Description Checks PostgreSQL, Redis and the click processor backlog
+// @Tags health
+// @Produce json
+// @Success 200 {object} ReadinessResponse
+// @Failure 503 {object} ReadinessResponse
+// @Router /readyz [get]
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	// Probe не ограничен rate limiter, поэтому одновременные запросы ждут общий прогон
+	// проверок, а не умножают запросы к PostgreSQL и Redis. Отмена запроса, запустившего
+	// прогон, не должна прерывать его для остальных: каждую проверку ограничивает timeout.
+	ctx := context.WithoutCancel(c.Request.Context())
+	components, _, _ := h.checking.Do("readyz", func() (any, error) {
+		return h.runChecks(ctx), nil
+	})
+
+	response := ReadinessResponse{
+		Status:     statusOK,
+		Components: components.(map[string]ComponentStatus),
+	}
+
+	for _, component := range response.Components {
+		if component.Status == componentUp {
+			continue
+		}
+		if !component.Optional {
+			response.Status = statusUnavailable
+		} else if response.Status == statusOK {
+			response.Status = statusDegraded
+		}
+	}
+
+	// При graceful shutdown отвечаем 503, чтобы балансировщик успел снять трафик
+	if h.draining.Load() {
+		response.Status = statusDraining
+	}
+
+	code := http.StatusOK
+	if response.Status == statusUnavailable || response.Status == statusDraining {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, response)
+}
+
+// runChecks выполняет проверки параллельно, каждую со своим таймаутом
+func (h *HealthHandler) runChecks(ctx context.Context) map[string]ComponentStatus {
+	results := make(map[string]ComponentStatus, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check ReadinessCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+			status := ComponentStatus{
+				Status:    componentUp,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Optional:  check.Optional,
+			}
+			if err != nil {
+				status.Status = componentDown
+				h.logger.Warn("Readiness check failed",
+					zap.String("component", check.Name),
+					zap.Bool("optional", check.Optional),
+					zap.Error(err),
+				)
+			}
+
+			mu.Lock()
+			results[check.Name] = status
+			mu.Unlock()
+		}(check)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// ClickBacklogCheck проверяет, что очередь кликов заполнена не более чем на maxRatio
+func ClickBacklogCheck(clickProcessor service.ClickProcessor, maxRatio float64) ReadinessCheck {
+	return ReadinessCheck{
+		Name: "click_processor",
+		Check: func(ctx context.Context) error {
+			stats := clickProcessor.GetChannelStats()
+			if stats.BufferSize == 0 {
+				return nil
+			}
+			if ratio := float64(stats.BufferUsed) / float64(stats.BufferSize); ratio >= maxRatio {
+				return fmt.Errorf("click backlog %d/%d exceeds %.0f%%", stats.BufferUsed, stats.BufferSize, maxRatio*100)
+			}
+			return nil
+		},
+	}
+}
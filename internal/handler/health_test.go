@@ -0,0 +1,158 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/handler"
+	"github.com/SergeiKhy/url-shortener/internal/middleware"
+	"github.com/SergeiKhy/url-shortener/internal/service"
+	"github.com/SergeiKhy/url-shortener/internal/service/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// serveReadyz выполняет запрос к readiness probe
+func serveReadyz(t *testing.T, health *handler.HealthHandler) (int, handler.ReadinessResponse) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/readyz", health.Readyz)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	router.ServeHTTP(w, req)
+
+	assert.NotContains(t, w.Body.String(), "error")
+
+	var resp handler.ReadinessResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+// TestHealthHandler_Readyz проверяет статусы компонентов и таймаут проверок
+func TestHealthHandler_Readyz(t *testing.T) {
+	ok := handler.ReadinessCheck{Name: "postgres", Check: func(ctx context.Context) error { return nil }}
+	failing := handler.ReadinessCheck{Name: "redis", Check: func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}}
+	hanging := handler.ReadinessCheck{Name: "slow", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	// Все зависимости доступны
+	code, resp := serveReadyz(t, handler.NewHealthHandler(time.Second, zap.NewNop(), ok))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", resp.Status)
+	assert.Equal(t, "up", resp.Components["postgres"].Status)
+
+	// Одна зависимость недоступна: текст ошибки пишется в лог, но не в ответ
+	core, logs := observer.New(zap.WarnLevel)
+	code, resp = serveReadyz(t, handler.NewHealthHandler(time.Second, zap.New(core), ok, failing))
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", resp.Status)
+	assert.Equal(t, "down", resp.Components["redis"].Status)
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "connection refused", logs.All()[0].ContextMap()["error"])
+
+	// Недоступность опциональной зависимости не снимает инстанс с балансировки
+	failing.Optional = true
+	code, resp = serveReadyz(t, handler.NewHealthHandler(time.Second, zap.NewNop(), ok, failing))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "degraded", resp.Status)
+	assert.Equal(t, "down", resp.Components["redis"].Status)
+
+	// Зависшая проверка ограничена таймаутом
+	start := time.Now()
+	code, resp = serveReadyz(t, handler.NewHealthHandler(50*time.Millisecond, zap.NewNop(), ok, hanging))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "down", resp.Components["slow"].Status)
+}
+
+// TestHealthHandler_Draining проверяет, что при graceful shutdown readiness отвечает 503
+func TestHealthHandler_Draining(t *testing.T) {
+	health := handler.NewHealthHandler(time.Second, zap.NewNop(), handler.ReadinessCheck{
+		Name:  "postgres",
+		Check: func(ctx context.Context) error { return nil },
+	})
+
+	code, _ := serveReadyz(t, health)
+	assert.Equal(t, http.StatusOK, code)
+
+	health.SetDraining()
+
+	code, resp := serveReadyz(t, health)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "draining", resp.Status)
+}
+
+// TestHealthHandler_ReadyzCoalesces проверяет, что одновременные запросы readiness
+// используют один прогон проверок
+func TestHealthHandler_ReadyzCoalesces(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	health := handler.NewHealthHandler(time.Second, zap.NewNop(), handler.ReadinessCheck{
+		Name: "postgres",
+		Check: func(ctx context.Context) error {
+			calls.Add(1)
+			<-release
+			return nil
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code, _ := serveReadyz(t, health)
+			assert.Equal(t, http.StatusOK, code)
+		}()
+	}
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 5*time.Millisecond)
+	// Даём остальным запросам присоединиться к прогону
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+// TestRouter_ProbesNotRateLimited проверяет, что общий rate limiter не отвечает 429 на probe
+func TestRouter_ProbesNotRateLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	linkService := service.NewLinkService(mocks.NewMockLinkRepository(), mocks.NewMockCacheRepository(), service.LinkServiceConfig{}, zap.NewNop())
+	rateLimiter := middleware.NewRateLimiter(middleware.RateLimiterConfig{RequestsPerSecond: 0.001, BurstSize: 1, CleanupInterval: time.Minute})
+	router, err := handler.NewRouter(linkService, &stubClickProcessor{}, rateLimiter, nil,
+		handler.NewHealthHandler(time.Second, zap.NewNop()), mustDomains(t), nil,
+		handler.LinkHandlerConfig{}, zap.NewNop())
+	require.NoError(t, err)
+
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.RemoteAddr = "10.0.0.1:12345"
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Лимит исчерпан первым запросом
+	serve("/api/v1/health")
+	assert.Equal(t, http.StatusTooManyRequests, serve("/api/v1/health"))
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve("/readyz"))
+		assert.Equal(t, http.StatusOK, serve("/livez"))
+	}
+}
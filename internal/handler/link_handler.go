@@ -1,42 +1,236 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/SergeiKhy/url-shortener/internal/geoip"
+	"github.com/SergeiKhy/url-shortener/internal/logging"
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
+	"github.com/SergeiKhy/url-shortener/internal/middleware"
 	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/SergeiKhy/url-shortener/internal/repository"
 	"github.com/SergeiKhy/url-shortener/internal/service"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// permanentRedirectMaxAge сколько браузеры и прокси могут кэшировать постоянный редирект
+const permanentRedirectMaxAge = 24 * time.Hour
+
+// PasswordHeader заголовок с паролем защищённой ссылки для API клиентов
+const PasswordHeader = "X-Link-Password"
+
+// PreviewSuffix суффикс кода, открывающий страницу предпросмотра вместо редиректа
+const PreviewSuffix = "+"
+
+// VariantCookie cookie с вариантом A/B теста, назначенным посетителю. Cookie
+// выставляется на путь ссылки, поэтому у каждой ссылки свой вариант.
+const VariantCookie = "link_variant"
+
+// variantCookieMaxAge сколько посетитель остаётся в назначенном варианте
+const variantCookieMaxAge = 30 * 24 * time.Hour
+
+// LinkHandlerConfig настройки обработчика ссылок
+type LinkHandlerConfig struct {
+	// Неудачные попытки ввода пароля с одного IP для одной ссылки:
+	// не больше PasswordMaxAttempts за PasswordAttemptWindow (по умолчанию 5 за 15 минут)
+	PasswordMaxAttempts   int
+	PasswordAttemptWindow time.Duration
+	// NotActiveURL адрес, на который уходят посетители ссылки до её активации.
+	// Пустой — показывается страница NotActivePage (по умолчанию встроенная).
+	NotActiveURL  string
+	NotActivePage *template.Template
+	// Страницы для браузеров по несуществующей и истёкшей ссылке (по умолчанию встроенные).
+	// Истёкшая ссылка с FallbackURL перенаправляет на него вместо страницы.
+	NotFoundPage *template.Template
+	ExpiredPage  *template.Template
+	// PreviewPage страница предпросмотра (по умолчанию встроенная)
+	PreviewPage *template.Template
+	// AppPage страница открытия приложения по deep link правила устройства (по умолчанию встроенная)
+	AppPage *template.Template
+	// GeoIP определяет страну посетителя для правил геотаргетинга и статистики.
+	// nil — страна не определяется, переходы идут на адрес ссылки по умолчанию.
+	GeoIP geoip.Resolver
+}
+
 type LinkHandler struct {
-	service         service.LinkService
-	clickProcessor  service.ClickProcessor
-	logger          *zap.Logger
+	service        service.LinkService
+	clickProcessor service.ClickProcessor
+	domains        *Domains
+	logger         *zap.Logger
+	redirectLogger *zap.Logger // сэмплируемый логгер для пути редиректа
+	// passwordAttempts лимит неудачных попыток ввода пароля по IP и ссылке
+	passwordAttempts *middleware.RateLimiter
+	// passwordRetryAfter через сколько восстанавливается одна попытка
+	passwordRetryAfter time.Duration
+	notActiveURL       string
+	notActivePage      *template.Template
+	notFoundPage       *template.Template
+	expiredPage        *template.Template
+	previewPage        *template.Template
+	appPage            *template.Template
+	geoIP              geoip.Resolver
 }
 
-func NewLinkHandler(service service.LinkService, clickProcessor service.ClickProcessor, logger *zap.Logger) *LinkHandler {
+func NewLinkHandler(service service.LinkService, clickProcessor service.ClickProcessor, domains *Domains, config LinkHandlerConfig, logger *zap.Logger) *LinkHandler {
+	if config.PasswordMaxAttempts <= 0 {
+		config.PasswordMaxAttempts = 5
+	}
+	if config.PasswordAttemptWindow <= 0 {
+		config.PasswordAttemptWindow = 15 * time.Minute
+	}
+	if config.NotActivePage == nil {
+		config.NotActivePage = notActivePage
+	}
+	if config.NotFoundPage == nil {
+		config.NotFoundPage = notFoundPage
+	}
+	if config.ExpiredPage == nil {
+		config.ExpiredPage = expiredPage
+	}
+	if config.PreviewPage == nil {
+		config.PreviewPage = previewPage
+	}
+	if config.AppPage == nil {
+		config.AppPage = appPage
+	}
+
+	// Попытки восстанавливаются равномерно: полный лимит — за PasswordAttemptWindow
+	retryAfter := config.PasswordAttemptWindow / time.Duration(config.PasswordMaxAttempts)
 	return &LinkHandler{
 		service:        service,
 		clickProcessor: clickProcessor,
+		domains:        domains,
 		logger:         logger,
+		redirectLogger: logger.Named(logging.RedirectLoggerName),
+		passwordAttempts: middleware.NewRateLimiter(middleware.RateLimiterConfig{
+			RequestsPerSecond: 1 / retryAfter.Seconds(),
+			BurstSize:         config.PasswordMaxAttempts,
+			CleanupInterval:   config.PasswordAttemptWindow,
+		}),
+		passwordRetryAfter: retryAfter,
+		notActiveURL:       config.NotActiveURL,
+		notActivePage:      config.NotActivePage,
+		notFoundPage:       config.NotFoundPage,
+		expiredPage:        config.ExpiredPage,
+		previewPage:        config.PreviewPage,
+		appPage:            config.AppPage,
+		geoIP:              config.GeoIP,
 	}
 }
 
+// log возвращает логгер с ID текущего запроса
+func (h *LinkHandler) log(c *gin.Context) *zap.Logger {
+	return logging.FromContext(c.Request.Context(), h.logger)
+}
+
+// queryDomain домен из параметра ?domain= для эндпоинтов управления ссылкой.
+// Если домен не настроен, отвечает 400 и возвращает false.
+func (h *LinkHandler) queryDomain(c *gin.Context) (string, bool) {
+	domain, ok := h.domains.Lookup(c.Query("domain"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "unknown_domain",
+			Message: "Domain is not configured",
+		})
+	}
+	return domain, ok
+}
+
 type CreateLinkRequest struct {
-	URL         string `json:"url" binding:"required,url"`
-	ExpiresIn   *int   `json:"expires_in,omitempty"`
-	CustomCode  string `json:"custom_code,omitempty"`
+	URL       string `json:"url" binding:"required,url"`
+	ExpiresIn *int   `json:"expires_in,omitempty"`
+	// ExpiresAt абсолютное время истечения (RFC 3339), вместо expires_in
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// StartsAt время активации (RFC 3339): до него ссылка не открывается
+	StartsAt   *time.Time `json:"starts_at,omitempty"`
+	CustomCode string     `json:"custom_code,omitempty"`
+	// Domain домен короткой ссылки, по умолчанию — домен APP_BASE_URL
+	Domain string `json:"domain,omitempty"`
+	// RedirectType HTTP статус редиректа (301, 302, 307, 308), по умолчанию — LINK_DEFAULT_REDIRECT_TYPE
+	RedirectType int `json:"redirect_type,omitempty"`
+	// Password пароль для перехода по ссылке (4-72 байта)
+	Password string `json:"password,omitempty"`
+	// MaxClicks максимум переходов, 1 — одноразовая ссылка
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// FallbackURL адрес, на который ведёт ссылка после истечения
+	FallbackURL string `json:"fallback_url,omitempty"`
+	// Title заголовок для страницы предпросмотра (до 200 символов)
+	Title string `json:"title,omitempty"`
+	// Preview всегда показывать страницу предпросмотра перед редиректом
+	Preview bool `json:"preview,omitempty"`
+	// GeoRules адреса назначения по коду страны (ISO 3166-1 alpha-2), для остальных стран — url
+	GeoRules map[string]string `json:"geo_rules,omitempty"`
+	// DeviceRules адреса назначения по платформе (ios, android, desktop), проверяются раньше geo_rules
+	DeviceRules models.DeviceRules `json:"device_rules,omitempty"`
+	// Variants варианты A/B теста (2-10): трафик без сработавших правил делится между ними по весам
+	Variants []models.Variant `json:"variants,omitempty"`
+}
+
+// UpdateLinkRequest изменяемые поля ссылки, отсутствующие поля не меняются
+type UpdateLinkRequest struct {
+	RedirectType *int `json:"redirect_type,omitempty"`
+	// Password новый пароль, пустая строка снимает защиту
+	Password *string `json:"password,omitempty"`
+	// MaxClicks новый лимит переходов, 0 снимает ограничение
+	MaxClicks *int `json:"max_clicks,omitempty"`
+	// FallbackURL новый адрес после истечения, пустая строка убирает его
+	FallbackURL *string `json:"fallback_url,omitempty"`
+	Title       *string `json:"title,omitempty"`
+	Preview     *bool   `json:"preview,omitempty"`
+	// GeoRules новые правила геотаргетинга целиком, пустой объект удаляет их
+	GeoRules map[string]string `json:"geo_rules,omitempty"`
+	// DeviceRules новые правила по устройству целиком, пустой объект удаляет их
+	DeviceRules models.DeviceRules `json:"device_rules,omitempty"`
+	// Variants новые варианты A/B теста целиком, пустой список завершает тест
+	Variants []models.Variant `json:"variants,omitempty"`
 }
 
 type CreateLinkResponse struct {
-	ShortCode   string     `json:"short_code"`
-	ShortURL    string     `json:"short_url"`
-	OriginalURL string     `json:"original_url"`
-	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
+	ShortCode         string             `json:"short_code"`
+	ShortURL          string             `json:"short_url"`
+	OriginalURL       string             `json:"original_url"`
+	Title             string             `json:"title,omitempty"`
+	Preview           bool               `json:"preview,omitempty"`
+	GeoRules          map[string]string  `json:"geo_rules,omitempty"`
+	DeviceRules       models.DeviceRules `json:"device_rules,omitempty"`
+	Variants          []models.Variant   `json:"variants,omitempty"`
+	RedirectType      int                `json:"redirect_type"`
+	PasswordProtected bool               `json:"password_protected"`
+	MaxClicks         int                `json:"max_clicks,omitempty"`
+	UsedClicks        int                `json:"used_clicks,omitempty"`
+	FallbackURL       string             `json:"fallback_url,omitempty"`
+	StartsAt          *time.Time         `json:"starts_at,omitempty"`
+	ExpiresAt         *time.Time         `json:"expires_at,omitempty"`
+	CreatedAt         time.Time          `json:"created_at"`
+}
+
+// linkResponse ответ API с данными ссылки
+func (h *LinkHandler) linkResponse(link *models.Link) CreateLinkResponse {
+	return CreateLinkResponse{
+		ShortCode:         link.ShortCode,
+		ShortURL:          h.domains.ShortURL(link.Domain, link.ShortCode),
+		OriginalURL:       link.OriginalURL,
+		Title:             link.Title,
+		Preview:           link.Preview,
+		GeoRules:          link.GeoRules,
+		DeviceRules:       link.DeviceRules,
+		Variants:          link.Variants,
+		RedirectType:      link.RedirectStatus(),
+		PasswordProtected: link.HasPassword(),
+		MaxClicks:         link.MaxClicks,
+		UsedClicks:        link.UsedClicks,
+		FallbackURL:       link.FallbackURL,
+		StartsAt:          link.StartsAt,
+		ExpiresAt:         link.ExpiresAt,
+		CreatedAt:         link.CreatedAt,
+	}
 }
 
 type ErrorResponse struct {
@@ -53,12 +247,13 @@ type ErrorResponse struct {
 // @Param request body CreateLinkRequest true "Link creation request"
 // @Success 201 {object} CreateLinkResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/links [post]
 func (h *LinkHandler) CreateLink(c *gin.Context) {
 	var req CreateLinkRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Warn("Invalid request body", zap.Error(err))
+		h.log(c).Warn("Invalid request body", zap.Error(err))
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "invalid_request",
 			Message: err.Error(),
@@ -66,9 +261,30 @@ func (h *LinkHandler) CreateLink(c *gin.Context) {
 		return
 	}
 
+	domain, ok := h.domains.Lookup(req.Domain)
+	if !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "unknown_domain",
+			Message: "Domain is not configured",
+		})
+		return
+	}
+
 	input := &models.CreateLinkInput{
-		OriginalURL: req.URL,
-		ExpiresIn:   req.ExpiresIn,
+		OriginalURL:  req.URL,
+		ExpiresIn:    req.ExpiresIn,
+		ExpiresAt:    req.ExpiresAt,
+		StartsAt:     req.StartsAt,
+		Domain:       domain,
+		RedirectType: req.RedirectType,
+		Password:     req.Password,
+		MaxClicks:    req.MaxClicks,
+		FallbackURL:  req.FallbackURL,
+		Title:        req.Title,
+		Preview:      req.Preview,
+		GeoRules:     req.GeoRules,
+		DeviceRules:  req.DeviceRules,
+		Variants:     req.Variants,
 	}
 
 	if req.CustomCode != "" {
@@ -77,7 +293,7 @@ func (h *LinkHandler) CreateLink(c *gin.Context) {
 
 	link, err := h.service.CreateLink(c.Request.Context(), input)
 	if err != nil {
-		h.logger.Error("Failed to create link", zap.Error(err))
+		h.log(c).Error("Failed to create link", zap.Error(err))
 
 		switch err {
 		case service.ErrInvalidURL:
@@ -95,6 +311,57 @@ func (h *LinkHandler) CreateLink(c *gin.Context) {
 				Error:   "spam_domain",
 				Message: "Domain is blacklisted",
 			})
+		case service.ErrInvalidRedirectType:
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_redirect_type",
+				Message: "Redirect type must be one of 301, 302, 307, 308",
+			})
+		case service.ErrInvalidPassword:
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_password",
+				Message: "Password must be 4-72 bytes long",
+			})
+		case service.ErrInvalidMaxClicks:
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_max_clicks",
+				Message: "max_clicks must not be negative",
+			})
+		case service.ErrInvalidFallbackURL:
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_fallback_url",
+				Message: "Invalid fallback URL format",
+			})
+		case service.ErrInvalidGeoRules:
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_geo_rules",
+				Message: "Geo rules must map two-letter country codes to valid URLs",
+			})
+		case service.ErrInvalidDeviceRules:
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_device_rules",
+				Message: "Device rules must map ios, android or desktop to a valid URL and an optional app deep link",
+			})
+		case service.ErrInvalidVariants:
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_variants",
+				Message: "Variants must be 2-10 entries with unique names (up to 32 letters, digits, _ or -), valid URLs and weights 1-1000",
+			})
+		case service.ErrInvalidTitle:
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_title",
+				Message: "Title must be at most 200 characters",
+			})
+		case service.ErrInvalidSchedule:
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_schedule",
+				Message: "expires_at must be in the future and after starts_at; use either expires_in or expires_at",
+			})
+		case repository.ErrCodeExists:
+			// Код занят другой ссылкой или выведен из оборота после удаления истёкшей ссылки
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "code_taken",
+				Message: "Custom code is already taken",
+			})
 		default:
 			c.JSON(http.StatusInternalServerError, ErrorResponse{
 				Error:   "internal_error",
@@ -104,28 +371,38 @@ func (h *LinkHandler) CreateLink(c *gin.Context) {
 		return
 	}
 
-	response := CreateLinkResponse{
-		ShortCode:   link.ShortCode,
-		ShortURL:    "http://localhost:8080/" + link.ShortCode,
-		OriginalURL: link.OriginalURL,
-		ExpiresAt:   link.ExpiresAt,
-		CreatedAt:   link.CreatedAt,
-	}
-
-	c.JSON(http.StatusCreated, response)
+	c.JSON(http.StatusCreated, h.linkResponse(link))
 }
 
 // Redirect godoc
 // @Summary Redirect to original URL
-// @Description Redirect to the original URL by short code
+// @Description Redirect to the original URL by short code. The link domain is taken from the Host header.
+// @Description The status is the link's redirect_type: permanent redirects (301, 308) are cacheable, temporary ones (302, 307) are sent with no-store.
+// @Description Password-protected links require the X-Link-Password header; browsers get an HTML password form that is submitted with POST.
+// @Description Appending + to the code, or the link's preview flag, shows a preview page instead; its Continue button submits a POST and only then the click is counted.
+// @Description Links with geo_rules redirect by the visitor's country (GeoIP), other countries go to the link's url.
+// @Description Links with device_rules redirect by the visitor's platform (User-Agent) before geo_rules are checked. A rule with deep_link gives browsers a page that opens the app and falls back to the rule's url.
+// @Description Links with variants split the remaining traffic by weight; the assigned variant is kept in the link_variant cookie, without it the choice is stable per IP and User-Agent.
+// @Description Missing links respond 404, expired or exhausted links respond 410 or redirect to the link's fallback_url. Browsers (Accept: text/html) get HTML pages instead of JSON errors.
 // @Tags links
 // @Produce json
+// @Produce html
 // @Param code path string true "Short code"
+// @Param X-Link-Password header string false "Password of a protected link"
+// @Success 200 {object} PreviewResponse
+// @Success 301 {object} nil
+// @Success 302 {object} nil
 // @Success 307 {object} nil
+// @Success 308 {object} nil
+// @Failure 401 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
 // @Router /{code} [get]
+// @Router /{code} [post]
 func (h *LinkHandler) Redirect(c *gin.Context) {
-	code := c.Param("code")
+	// Код с «+» на конце открывает страницу предпросмотра вместо редиректа
+	code, preview := strings.CutSuffix(c.Param("code"), PreviewSuffix)
 	if code == "" {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "missing_code",
@@ -134,29 +411,363 @@ func (h *LinkHandler) Redirect(c *gin.Context) {
 		return
 	}
 
-	link, err := h.service.GetLink(c.Request.Context(), code)
-	if err != nil {
-		h.logger.Warn("Link not found", zap.String("code", code), zap.Error(err))
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+	domain := h.domains.Resolve(c.Request.Host)
+	link, err := h.service.GetLink(c.Request.Context(), domain, code)
+	switch {
+	case errors.Is(err, service.ErrLinkNotActive):
+		h.notActive(c, domain, code)
+		return
+	case errors.Is(err, service.ErrLinkExpired):
+		h.gone(c, link, ErrorResponse{
+			Error:   "link_expired",
+			Message: "Link has expired",
+		})
+		return
+	case errors.Is(err, repository.ErrLinkNotFound):
+		logging.FromContext(c.Request.Context(), h.redirectLogger).
+			Warn("Link not found", zap.String("domain", domain), zap.String("code", code))
+		renderStatus(c, http.StatusNotFound, h.notFoundPage, linkPageData{Domain: domain, Code: code}, ErrorResponse{
 			Error:   "not_found",
-			Message: "Link not found or expired",
+			Message: "Link not found",
+		})
+		return
+	case err != nil:
+		logging.FromContext(c.Request.Context(), h.redirectLogger).
+			Error("Failed to get link", zap.String("domain", domain), zap.String("code", code), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to follow link",
 		})
 		return
 	}
 
+	if link.HasPassword() && !h.authorizePassword(c, link) {
+		return
+	}
+
+	// Предпросмотр не показывает адрес защищённой ссылки: для неё промежуточной
+	// страницей служит форма пароля. Переход засчитывается только после «Continue».
+	visitor := h.visitor(c)
+	target := link.Target(visitor)
+
+	if (preview || link.Preview) && !link.HasPassword() && !previewContinued(c) {
+		// Исчерпанная ссылка не показывается и на странице предпросмотра
+		if err := h.service.CheckClicksLeft(c.Request.Context(), link); err != nil {
+			h.clickLimitError(c, link, err)
+			return
+		}
+		h.preview(c, link, target.URL)
+		return
+	}
+
+	// Переход по ссылке с лимитом засчитывается до редиректа
+	if err := h.service.ConsumeClick(c.Request.Context(), link); err != nil {
+		h.clickLimitError(c, link, err)
+		return
+	}
+
 	// Асинхронная запись статистики
 	clickEvent := &models.ClickEvent{
+		Domain:    domain,
 		ShortCode: code,
 		IPAddress: c.ClientIP(),
 		UserAgent: c.Request.UserAgent(),
 		Referer:   c.Request.Referer(),
-		Country:   "", // Can be populated via GeoIP lookup
+		Country:   visitor.Country,
+		Rule:      target.Rule,
+		Variant:   target.Variant,
 	}
 	if err := h.clickProcessor.RecordClick(c.Request.Context(), clickEvent); err != nil {
-		h.logger.Debug("Failed to record click (non-blocking)", zap.Error(err))
+		logging.FromContext(c.Request.Context(), h.redirectLogger).
+			Debug("Failed to record click (non-blocking)", zap.Error(err))
+	}
+
+	if target.Variant != "" && target.Variant != visitor.Variant {
+		h.rememberVariant(c, code, target.Variant)
+	}
+
+	// Deep link открывает только браузер: API клиенты получают обычный редирект на URL правила
+	if target.DeepLink != "" && acceptsHTML(c) {
+		renderPage(c, http.StatusOK, h.appPage, appPageData{
+			linkPageData: linkPageData{Domain: link.Domain, Code: link.ShortCode},
+			DeepLink:     template.URL(target.DeepLink),
+			Destination:  target.URL,
+		})
+		return
+	}
+
+	status := link.RedirectStatus()
+	c.Header("Cache-Control", redirectCacheControl(link, status))
+	if c.Request.Method == http.MethodPost {
+		// После отправки формы пароля браузер должен перейти по ссылке GET запросом
+		status = http.StatusSeeOther
+	}
+	c.Redirect(status, target.URL)
+}
+
+// visitor данные посетителя для выбора адреса назначения ссылки
+func (h *LinkHandler) visitor(c *gin.Context) models.Visitor {
+	visitor := models.Visitor{
+		Platform:    models.ParsePlatform(c.Request.UserAgent()),
+		Fingerprint: c.ClientIP() + " " + c.Request.UserAgent(),
+	}
+	// Cookie выставлена на путь ссылки, браузер не присылает её для других кодов
+	visitor.Variant, _ = c.Cookie(VariantCookie)
+	if h.geoIP != nil {
+		visitor.Country = h.geoIP.Country(c.ClientIP())
+	}
+	return visitor
+}
+
+// rememberVariant закрепляет за посетителем вариант A/B теста ссылки
+func (h *LinkHandler) rememberVariant(c *gin.Context, code, variant string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(VariantCookie, variant, int(variantCookieMaxAge.Seconds()), "/"+code, "", c.Request.TLS != nil, true)
+}
+
+// notActive ответ на переход по ссылке до её активации: редирект на NotActiveURL или страница
+func (h *LinkHandler) notActive(c *gin.Context, domain, code string) {
+	if h.notActiveURL != "" {
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, h.notActiveURL)
+		return
+	}
+	renderStatus(c, http.StatusNotFound, h.notActivePage, linkPageData{Domain: domain, Code: code}, ErrorResponse{
+		Error:   "not_active",
+		Message: "Link is not active yet",
+	})
+}
+
+// clickLimitError ответ на ошибку проверки лимита переходов: 410 или переход на
+// FallbackURL для исчерпанной ссылки, 500 для остальных ошибок
+func (h *LinkHandler) clickLimitError(c *gin.Context, link *models.Link, err error) {
+	if errors.Is(err, service.ErrLinkExhausted) {
+		h.gone(c, link, ErrorResponse{
+			Error:   "link_exhausted",
+			Message: "Link has reached its click limit",
+		})
+		return
+	}
+	logging.FromContext(c.Request.Context(), h.redirectLogger).
+		Error("Failed to check link click limit", zap.String("domain", link.Domain), zap.String("code", link.ShortCode), zap.Error(err))
+	c.JSON(http.StatusInternalServerError, ErrorResponse{
+		Error:   "internal_error",
+		Message: "Failed to follow link",
+	})
+}
+
+// PreviewResponse данные предпросмотра для API клиентов. Правила, варианты и
+// настройки ссылки не раскрываются: предпросмотр доступен без API ключа.
+type PreviewResponse struct {
+	// Destination адрес назначения для этого посетителя, пустой у ссылки с лимитом переходов
+	Destination string    `json:"destination,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// preview страница предпросмотра с адресом назначения для этого посетителя. Адрес
+// ссылки с лимитом переходов не показывается: его раскрывает только засчитанный переход.
+func (h *LinkHandler) preview(c *gin.Context, link *models.Link, destination string) {
+	metrics.LinkPreviews.Inc()
+	if link.ClickLimited() {
+		destination = ""
+	}
+	renderStatus(c, http.StatusOK, h.previewPage, previewPageData{
+		linkPageData: linkPageData{Domain: link.Domain, Code: link.ShortCode},
+		Title:        link.Title,
+		Destination:  destination,
+		CreatedAt:    link.CreatedAt,
+		Action:       h.domains.ShortURL(link.Domain, link.ShortCode),
+	}, PreviewResponse{
+		Destination: destination,
+		Title:       link.Title,
+		CreatedAt:   link.CreatedAt,
+	})
+}
+
+// previewContinued посетитель нажал «Continue» на странице предпросмотра
+func previewContinued(c *gin.Context) bool {
+	return c.Request.Method == http.MethodPost && c.PostForm("continue") != ""
+}
+
+// gone ответ на переход по истёкшей ссылке или ссылке с исчерпанным лимитом:
+// редирект на FallbackURL ссылки или страница 410
+func (h *LinkHandler) gone(c *gin.Context, link *models.Link, errResp ErrorResponse) {
+	if link.FallbackURL != "" {
+		metrics.ExpiredLinkRedirects.WithLabelValues(metrics.ExpiredFallback).Inc()
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, link.FallbackURL)
+		return
+	}
+	metrics.ExpiredLinkRedirects.WithLabelValues(metrics.ExpiredGone).Inc()
+	renderStatus(c, http.StatusGone, h.expiredPage, linkPageData{Domain: link.Domain, Code: link.ShortCode}, errResp)
+}
+
+// authorizePassword проверяет пароль из заголовка X-Link-Password или формы.
+// Без пароля отвечает формой ввода. Неудачные попытки ограничены по IP и ссылке:
+// попытка резервируется до проверки пароля и возвращается в лимит при верном пароле,
+// при исчерпании лимита пароль не проверяется.
+func (h *LinkHandler) authorizePassword(c *gin.Context, link *models.Link) bool {
+	password := c.GetHeader(PasswordHeader)
+	fromHeader := password != ""
+	if !fromHeader && c.Request.Method == http.MethodPost {
+		password = c.PostForm("password")
+	}
+	if password == "" {
+		renderPage(c, http.StatusUnauthorized, passwordPage, passwordPageData{})
+		return false
+	}
+
+	key := c.ClientIP() + "|" + models.LinkKey(link.Domain, link.ShortCode)
+	release, ok := h.passwordAttempts.Reserve(key)
+	if !ok {
+		metrics.LinkPasswordChecks.WithLabelValues(metrics.PasswordLimited).Inc()
+		c.Header("Retry-After", strconv.Itoa(int(h.passwordRetryAfter.Seconds())))
+		h.passwordError(c, fromHeader, http.StatusTooManyRequests, "too_many_attempts", "Too many failed password attempts, try again later")
+		return false
+	}
+
+	if err := h.service.CheckPassword(c.Request.Context(), link, password); err != nil {
+		metrics.LinkPasswordChecks.WithLabelValues(metrics.PasswordInvalid).Inc()
+		logging.FromContext(c.Request.Context(), h.redirectLogger).
+			Info("Wrong link password", zap.String("domain", link.Domain), zap.String("code", link.ShortCode))
+		h.passwordError(c, fromHeader, http.StatusUnauthorized, "wrong_password", "Wrong password")
+		return false
+	}
+
+	release()
+	metrics.LinkPasswordChecks.WithLabelValues(metrics.PasswordValid).Inc()
+	return true
+}
+
+// passwordError ответ на неудачную проверку пароля: JSON для API клиентов, форма для браузера
+func (h *LinkHandler) passwordError(c *gin.Context, fromHeader bool, status int, code, message string) {
+	if fromHeader {
+		c.JSON(status, ErrorResponse{Error: code, Message: message})
+		return
 	}
+	renderPage(c, status, passwordPage, passwordPageData{Error: message})
+}
 
-	c.Redirect(http.StatusTemporaryRedirect, link.OriginalURL)
+// redirectCacheControl постоянный редирект разрешено кэшировать (но не дольше срока
+// действия ссылки), временный не кэшируется, чтобы каждый переход попадал в статистику.
+// Защищённые паролем ссылки и ссылки с лимитом переходов не кэшируются: иначе повторный
+// переход обошёл бы проверку. Ссылки с правилами не кэшируются, чтобы общий кэш не
+// отдал посетителю адрес, выбранный для другого.
+func redirectCacheControl(link *models.Link, status int) string {
+	if !models.IsPermanentRedirect(status) || link.HasPassword() || link.ClickLimited() || link.HasRules() {
+		return "no-store"
+	}
+	maxAge := permanentRedirectMaxAge
+	if link.ExpiresAt != nil {
+		maxAge = min(maxAge, time.Until(*link.ExpiresAt))
+	}
+	if maxAge <= 0 {
+		return "no-store"
+	}
+	return "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+}
+
+// UpdateLink godoc
+// @Summary Update a short link
+// @Description Update mutable link settings. Omitted fields are left unchanged.
+// @Tags links
+// @Accept json
+// @Produce json
+// @Param code path string true "Short code"
+// @Param domain query string false "Link domain (default domain if empty)"
+// @Param request body UpdateLinkRequest true "Link update request"
+// @Success 200 {object} CreateLinkResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/links/{code} [patch]
+func (h *LinkHandler) UpdateLink(c *gin.Context) {
+	code := c.Param("code")
+	domain, ok := h.queryDomain(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.log(c).Warn("Invalid request body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	link, err := h.service.UpdateLink(c.Request.Context(), domain, code, &models.UpdateLinkInput{
+		RedirectType: req.RedirectType,
+		Password:     req.Password,
+		MaxClicks:    req.MaxClicks,
+		FallbackURL:  req.FallbackURL,
+		Title:        req.Title,
+		Preview:      req.Preview,
+		GeoRules:     req.GeoRules,
+		DeviceRules:  req.DeviceRules,
+		Variants:     req.Variants,
+	})
+	if err != nil {
+		h.log(c).Warn("Failed to update link", zap.String("code", code), zap.Error(err))
+
+		switch {
+		case errors.Is(err, service.ErrInvalidRedirectType):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_redirect_type",
+				Message: "Redirect type must be one of 301, 302, 307, 308",
+			})
+		case errors.Is(err, service.ErrInvalidPassword):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_password",
+				Message: "Password must be 4-72 bytes long",
+			})
+		case errors.Is(err, service.ErrInvalidMaxClicks):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_max_clicks",
+				Message: "max_clicks must not be negative",
+			})
+		case errors.Is(err, service.ErrInvalidFallbackURL):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_fallback_url",
+				Message: "Invalid fallback URL format",
+			})
+		case errors.Is(err, service.ErrInvalidGeoRules):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_geo_rules",
+				Message: "Geo rules must map two-letter country codes to valid URLs",
+			})
+		case errors.Is(err, service.ErrInvalidDeviceRules):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_device_rules",
+				Message: "Device rules must map ios, android or desktop to a valid URL and an optional app deep link",
+			})
+		case errors.Is(err, service.ErrInvalidVariants):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_variants",
+				Message: "Variants must be 2-10 entries with unique names (up to 32 letters, digits, _ or -), valid URLs and weights 1-1000",
+			})
+		case errors.Is(err, service.ErrInvalidTitle):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "invalid_title",
+				Message: "Title must be at most 200 characters",
+			})
+		case errors.Is(err, repository.ErrLinkNotFound):
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Link not found",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "internal_error",
+				Message: "Failed to update link",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, h.linkResponse(link))
 }
 
 // DeleteLink godoc
@@ -165,15 +776,21 @@ func (h *LinkHandler) Redirect(c *gin.Context) {
 // @Tags links
 // @Produce json
 // @Param code path string true "Short code"
+// @Param domain query string false "Link domain (default domain if empty)"
 // @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Router /api/v1/links/{code} [delete]
 func (h *LinkHandler) DeleteLink(c *gin.Context) {
 	code := c.Param("code")
+	domain, ok := h.queryDomain(c)
+	if !ok {
+		return
+	}
 
-	err := h.service.DeleteLink(c.Request.Context(), code)
+	err := h.service.DeleteLink(c.Request.Context(), domain, code)
 	if err != nil {
-		h.logger.Warn("Failed to delete link", zap.String("code", code), zap.Error(err))
+		h.log(c).Warn("Failed to delete link", zap.String("code", code), zap.Error(err))
 		c.JSON(http.StatusNotFound, ErrorResponse{
 			Error:   "not_found",
 			Message: "Link not found",
@@ -186,19 +803,25 @@ func (h *LinkHandler) DeleteLink(c *gin.Context) {
 
 // GetStats godoc
 // @Summary Get click statistics for a short link
-// @Description Get total and unique click counts for a shortened URL
+// @Description Get total and unique click counts for a shortened URL, with per-variant counts for A/B tested links
 // @Tags links
 // @Produce json
 // @Param code path string true "Short code"
+// @Param domain query string false "Link domain (default domain if empty)"
 // @Success 200 {object} models.ClickStats
+// @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Router /api/v1/links/{code}/stats [get]
 func (h *LinkHandler) GetStats(c *gin.Context) {
 	code := c.Param("code")
+	domain, ok := h.queryDomain(c)
+	if !ok {
+		return
+	}
 
-	stats, err := h.clickProcessor.GetStats(c.Request.Context(), code)
+	stats, err := h.clickProcessor.GetStats(c.Request.Context(), domain, code)
 	if err != nil {
-		h.logger.Warn("Failed to get stats", zap.String("code", code), zap.Error(err))
+		h.log(c).Warn("Failed to get stats", zap.String("code", code), zap.Error(err))
 		c.JSON(http.StatusNotFound, ErrorResponse{
 			Error:   "not_found",
 			Message: "Link not found",
@@ -211,16 +834,22 @@ func (h *LinkHandler) GetStats(c *gin.Context) {
 
 // GetDailyStats godoc
 // @Summary Get daily click statistics
-// @Description Get daily click counts for a shortened URL
+// @Description Get daily click counts for a shortened URL, with per-variant counts for A/B tested links
 // @Tags links
 // @Produce json
 // @Param code path string true "Short code"
+// @Param domain query string false "Link domain (default domain if empty)"
 // @Param days query int false "Number of days" default(7)
 // @Success 200 {array} models.DailyClickStats
+// @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Router /api/v1/links/{code}/stats/daily [get]
 func (h *LinkHandler) GetDailyStats(c *gin.Context) {
 	code := c.Param("code")
+	domain, ok := h.queryDomain(c)
+	if !ok {
+		return
+	}
 	days := 7
 	if d := c.Query("days"); d != "" {
 		if _, err := fmt.Sscanf(d, "%d", &days); err != nil || days < 1 || days > 90 {
@@ -228,9 +857,9 @@ func (h *LinkHandler) GetDailyStats(c *gin.Context) {
 		}
 	}
 
-	stats, err := h.clickProcessor.GetDailyStats(c.Request.Context(), code, days)
+	stats, err := h.clickProcessor.GetDailyStats(c.Request.Context(), domain, code, days)
 	if err != nil {
-		h.logger.Warn("Failed to get daily stats", zap.String("code", code), zap.Error(err))
+		h.log(c).Warn("Failed to get daily stats", zap.String("code", code), zap.Error(err))
 		c.JSON(http.StatusNotFound, ErrorResponse{
 			Error:   "not_found",
 			Message: "Link not found",
@@ -0,0 +1,654 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/handler"
+	"github.com/SergeiKhy/url-shortener/internal/middleware"
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/SergeiKhy/url-shortener/internal/repository"
+	"github.com/SergeiKhy/url-shortener/internal/service"
+	"github.com/SergeiKhy/url-shortener/internal/service/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubClickProcessor процессор кликов, который только считает клики и запоминает последний
+type stubClickProcessor struct {
+	service.ClickProcessor
+	clicks int
+	last   *models.ClickEvent
+}
+
+func (s *stubClickProcessor) RecordClick(ctx context.Context, event *models.ClickEvent) error {
+	s.clicks++
+	s.last = event
+	return nil
+}
+
+// stubGeoIP страны по IP адресам из таблицы
+type stubGeoIP map[string]string
+
+func (s stubGeoIP) Country(ip string) string {
+	return s[ip]
+}
+
+// setupLinkRouter роутер редиректа поверх сервиса ссылок с моковыми репозиториями
+func setupLinkRouter(t *testing.T, config handler.LinkHandlerConfig) (*gin.Engine, service.LinkService, *stubClickProcessor) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	linkService := service.NewLinkService(mocks.NewMockLinkRepository(), mocks.NewMockCacheRepository(), service.LinkServiceConfig{}, zap.NewNop())
+	clicks := &stubClickProcessor{}
+	linkHandler := handler.NewLinkHandler(linkService, clicks, mustDomains(t), config, zap.NewNop())
+	router := gin.New()
+	router.GET("/:code", linkHandler.Redirect)
+	router.POST("/:code", linkHandler.Redirect)
+	return router, linkService, clicks
+}
+
+// TestLinkHandler_PasswordProtected проверяет форму пароля, проверку через заголовок и форму
+func TestLinkHandler_PasswordProtected(t *testing.T) {
+	router, linkService, clicks := setupLinkRouter(t, handler.LinkHandlerConfig{})
+	code := "secret"
+	_, err := linkService.CreateLink(context.Background(), &models.CreateLinkInput{
+		OriginalURL: "https://docs.example.com/internal",
+		CustomCode:  &code,
+		Password:    "s3cret-pass",
+	})
+	require.NoError(t, err)
+
+	// Без пароля — HTML форма
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/secret", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `<form method="post">`)
+
+	// Неверный пароль в заголовке — JSON ошибка
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/secret", nil)
+	req.Header.Set(handler.PasswordHeader, "wrong")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	var errResp handler.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, "wrong_password", errResp.Error)
+
+	// Верный пароль в заголовке — редирект, который не кэшируется
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/secret", nil)
+	req.Header.Set(handler.PasswordHeader, "s3cret-pass")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://docs.example.com/internal", w.Header().Get("Location"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	// Верный пароль из формы — 303, чтобы браузер перешёл GET запросом
+	form := url.Values{"password": {"s3cret-pass"}}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/secret", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "https://docs.example.com/internal", w.Header().Get("Location"))
+
+	// Учитываются только переходы после проверки пароля
+	assert.Equal(t, 2, clicks.clicks)
+}
+
+// TestLinkHandler_PasswordAttemptsLimited проверяет лимит неудачных попыток по IP и ссылке
+func TestLinkHandler_PasswordAttemptsLimited(t *testing.T) {
+	router, linkService, _ := setupLinkRouter(t, handler.LinkHandlerConfig{
+		PasswordMaxAttempts:   2,
+		PasswordAttemptWindow: time.Hour,
+	})
+	code := "limited"
+	_, err := linkService.CreateLink(context.Background(), &models.CreateLinkInput{
+		OriginalURL: "https://docs.example.com/limited",
+		CustomCode:  &code,
+		Password:    "s3cret-pass",
+	})
+	require.NoError(t, err)
+
+	attempt := func(ip, password string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/limited", nil)
+		req.RemoteAddr = ip + ":12345"
+		req.Header.Set(handler.PasswordHeader, password)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Верный пароль не расходует попытки
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusTemporaryRedirect, attempt("10.0.0.1", "s3cret-pass").Code)
+	}
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, attempt("10.0.0.1", "wrong").Code)
+	}
+
+	// Лимит исчерпан: даже верный пароль не проверяется
+	w := attempt("10.0.0.1", "s3cret-pass")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1800", w.Header().Get("Retry-After"))
+
+	// Другой IP не затронут
+	assert.Equal(t, http.StatusTemporaryRedirect, attempt("10.0.0.2", "s3cret-pass").Code)
+}
+
+// TestLinkHandler_PasswordLimitForwardedFor проверяет, что подмена X-Forwarded-For не
+// сбрасывает лимит попыток, если запрос пришёл не от доверенного прокси
+func TestLinkHandler_PasswordLimitForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(trustedProxies []string) *gin.Engine {
+		linkService := service.NewLinkService(mocks.NewMockLinkRepository(), mocks.NewMockCacheRepository(), service.LinkServiceConfig{}, zap.NewNop())
+		rateLimiter := middleware.NewRateLimiter(middleware.RateLimiterConfig{RequestsPerSecond: 100, BurstSize: 100, CleanupInterval: time.Minute})
+		router, err := handler.NewRouter(linkService, &stubClickProcessor{}, rateLimiter, nil,
+			handler.NewHealthHandler(time.Second, zap.NewNop()), mustDomains(t), trustedProxies,
+			handler.LinkHandlerConfig{PasswordMaxAttempts: 2, PasswordAttemptWindow: time.Hour}, zap.NewNop())
+		require.NoError(t, err)
+
+		code := "limited"
+		_, err = linkService.CreateLink(context.Background(), &models.CreateLinkInput{
+			OriginalURL: "https://docs.example.com/limited",
+			CustomCode:  &code,
+			Password:    "s3cret-pass",
+		})
+		require.NoError(t, err)
+		return router
+	}
+
+	attempt := func(router *gin.Engine, i int) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/limited", nil)
+		req.RemoteAddr = "10.0.0.1:12345"
+		req.Header.Set("X-Forwarded-For", "203.0.113."+strconv.Itoa(i))
+		req.Header.Set(handler.PasswordHeader, "wrong")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Без доверенных прокси заголовок игнорируется: все попытки идут в один лимит
+	router := newRouter(nil)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, attempt(router, i))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, attempt(router, 2))
+
+	// За доверенным прокси адрес клиента берётся из заголовка
+	router = newRouter([]string{"10.0.0.0/8"})
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusUnauthorized, attempt(router, i))
+	}
+}
+
+// TestLinkHandler_ClickLimit проверяет ответ 410 после исчерпания лимита переходов
+func TestLinkHandler_ClickLimit(t *testing.T) {
+	router, linkService, clicks := setupLinkRouter(t, handler.LinkHandlerConfig{})
+	code := "invite1"
+	_, err := linkService.CreateLink(context.Background(), &models.CreateLinkInput{
+		OriginalURL:  "https://example.com/invite",
+		CustomCode:   &code,
+		RedirectType: models.RedirectPermanent,
+		MaxClicks:    2,
+	})
+	require.NoError(t, err)
+
+	redirect := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/invite1", nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		w := redirect()
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+		// Постоянный редирект ссылки с лимитом не кэшируется браузером
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	}
+
+	w := redirect()
+	assert.Equal(t, http.StatusGone, w.Code)
+	var errResp handler.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, "link_exhausted", errResp.Error)
+	assert.Equal(t, 2, clicks.clicks)
+}
+
+// TestLinkHandler_CodeTaken проверяет ответ 409 на занятый и выведенный из оборота код
+func TestLinkHandler_CodeTaken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	linkRepo := mocks.NewMockLinkRepository()
+	linkService := service.NewLinkService(linkRepo, mocks.NewMockCacheRepository(), service.LinkServiceConfig{}, zap.NewNop())
+	linkHandler := handler.NewLinkHandler(linkService, &stubClickProcessor{}, mustDomains(t), handler.LinkHandlerConfig{}, zap.NewNop())
+	router := gin.New()
+	router.POST("/api/v1/links", linkHandler.CreateLink)
+
+	expired := time.Now().Add(-time.Hour)
+	require.NoError(t, linkRepo.Create(context.Background(), &models.Link{ShortCode: "taken1", OriginalURL: "https://example.com"}))
+	require.NoError(t, linkRepo.Create(context.Background(), &models.Link{ShortCode: "retired1", OriginalURL: "https://example.com", ExpiresAt: &expired}))
+	_, err := linkRepo.PurgeExpired(context.Background(), time.Now(), 10, repository.PurgeOptions{RetireCodes: true})
+	require.NoError(t, err)
+
+	for _, code := range []string{"taken1", "retired1"} {
+		w := httptest.NewRecorder()
+		body := `{"url": "https://example.org", "custom_code": "` + code + `"}`
+		req, _ := http.NewRequest("POST", "/api/v1/links", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code, code)
+		var errResp handler.ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+		assert.Equal(t, "code_taken", errResp.Error)
+	}
+}
+
+// TestLinkHandler_NotActive проверяет ответ до времени активации: страница, JSON или fallback URL
+func TestLinkHandler_NotActive(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "not-active.html")
+	require.NoError(t, os.WriteFile(templatePath, []byte(`<p>{{.Code}} opens soon</p>`), 0o644))
+	page, err := handler.ParsePageTemplate(templatePath)
+	require.NoError(t, err)
+
+	router, linkService, clicks := setupLinkRouter(t, handler.LinkHandlerConfig{NotActivePage: page})
+	code := "launch1"
+	startsAt := time.Now().Add(time.Hour)
+	_, err = linkService.CreateLink(context.Background(), &models.CreateLinkInput{
+		OriginalURL: "https://example.com/campaign",
+		CustomCode:  &code,
+		StartsAt:    &startsAt,
+	})
+	require.NoError(t, err)
+
+	redirect := func(router *gin.Engine, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/launch1", nil)
+		req.Header.Set("Accept", accept)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Браузер получает страницу из шаблона
+	w := redirect(router, "text/html,application/xhtml+xml,*/*;q=0.8")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "<p>launch1 opens soon</p>", w.Body.String())
+
+	// API клиент — JSON ошибку
+	w = redirect(router, "application/json")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var errResp handler.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, "not_active", errResp.Error)
+	assert.Zero(t, clicks.clicks)
+
+	// С NotActiveURL посетители уходят на fallback адрес
+	linkHandler := handler.NewLinkHandler(linkService, clicks, mustDomains(t), handler.LinkHandlerConfig{
+		NotActiveURL: "https://example.com/coming-soon",
+	}, zap.NewNop())
+	fallbackRouter := gin.New()
+	fallbackRouter.GET("/:code", linkHandler.Redirect)
+	w = redirect(fallbackRouter, "text/html")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/coming-soon", w.Header().Get("Location"))
+}
+
+func mustDomains(t *testing.T) *handler.Domains {
+	t.Helper()
+	domains, err := handler.NewDomains("http://localhost:8080", nil)
+	require.NoError(t, err)
+	return domains
+}
+
+// TestLinkHandler_ExpiredAndNotFound проверяет ответы 404 и 410 и переход на fallback URL
+func TestLinkHandler_ExpiredAndNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	linkRepo := mocks.NewMockLinkRepository()
+	expired := time.Now().Add(-time.Hour)
+	require.NoError(t, linkRepo.Create(ctx, &models.Link{ShortCode: "gone0001", OriginalURL: "https://example.com/a", ExpiresAt: &expired}))
+	require.NoError(t, linkRepo.Create(ctx, &models.Link{
+		ShortCode:   "gone0002",
+		OriginalURL: "https://example.com/b",
+		FallbackURL: "https://example.com/archive",
+		ExpiresAt:   &expired,
+	}))
+
+	notFoundPage, err := template.New("not-found").Parse(`<p>{{.Code}} not found</p>`)
+	require.NoError(t, err)
+	linkService := service.NewLinkService(linkRepo, mocks.NewMockCacheRepository(), service.LinkServiceConfig{}, zap.NewNop())
+	clicks := &stubClickProcessor{}
+	linkHandler := handler.NewLinkHandler(linkService, clicks, mustDomains(t), handler.LinkHandlerConfig{NotFoundPage: notFoundPage}, zap.NewNop())
+	router := gin.New()
+	router.GET("/:code", linkHandler.Redirect)
+
+	redirect := func(code, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/"+code, nil)
+		req.Header.Set("Accept", accept)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Несуществующая ссылка: JSON для API клиентов, страница из шаблона для браузера
+	w := redirect("missing1", "application/json")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var errResp handler.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, "not_found", errResp.Error)
+
+	w = redirect("missing1", "text/html")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "<p>missing1 not found</p>", w.Body.String())
+
+	// Истёкшая ссылка без fallback — 410 и встроенная страница
+	w = redirect("gone0001", "application/json")
+	assert.Equal(t, http.StatusGone, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, "link_expired", errResp.Error)
+
+	w = redirect("gone0001", "text/html")
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), "Link expired")
+
+	// Истёкшая ссылка с fallback — временный редирект на него
+	w = redirect("gone0002", "text/html")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/archive", w.Header().Get("Location"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	assert.Zero(t, clicks.clicks)
+}
+
+// TestLinkHandler_Preview проверяет страницу предпросмотра по «+» и по флагу ссылки
+func TestLinkHandler_Preview(t *testing.T) {
+	router, linkService, clicks := setupLinkRouter(t, handler.LinkHandlerConfig{})
+	for code, preview := range map[string]bool{"report1": false, "report2": true} {
+		_, err := linkService.CreateLink(context.Background(), &models.CreateLinkInput{
+			OriginalURL: "https://files.example.com/q3.pdf",
+			CustomCode:  &code,
+			Title:       "Q3 <report>",
+			Preview:     preview,
+			FallbackURL: "https://files.example.com/archive",
+		})
+		require.NoError(t, err)
+	}
+
+	request := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Accept", "text/html")
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// «+» показывает страницу, переход не засчитывается
+	w := request("GET", "/report1+", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	body := w.Body.String()
+	assert.Contains(t, body, "https://files.example.com/q3.pdf")
+	assert.Contains(t, body, "Q3 &lt;report&gt;")
+	assert.Contains(t, body, `action="http://localhost:8080/report1"`)
+	assert.Zero(t, clicks.clicks)
+
+	// Без «+» обычный редирект
+	assert.Equal(t, http.StatusTemporaryRedirect, request("GET", "/report1", nil).Code)
+
+	// Ссылка с флагом всегда начинается с предпросмотра, переход засчитывается после «Continue»
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, request("GET", "/report2", nil).Code)
+	}
+	w = request("POST", "/report2", url.Values{"continue": {"1"}})
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "https://files.example.com/q3.pdf", w.Header().Get("Location"))
+	assert.Equal(t, 2, clicks.clicks)
+
+	// API клиенты получают только адрес назначения, заголовок и дату создания
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/report1+", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "https://files.example.com/q3.pdf", resp["destination"])
+	assert.Equal(t, "Q3 <report>", resp["title"])
+	assert.Contains(t, resp, "created_at")
+	assert.NotContains(t, resp, "fallback_url")
+	assert.Len(t, resp, 3)
+}
+
+// TestLinkHandler_PreviewFormAction проверяет, что кнопка предпросмотра ведёт на адрес
+// ссылки с путём базового URL и на её домен
+func TestLinkHandler_PreviewFormAction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	domains, err := handler.NewDomains("https://example.com/s", []string{"https://go.example.com"})
+	require.NoError(t, err)
+	linkService := service.NewLinkService(mocks.NewMockLinkRepository(), mocks.NewMockCacheRepository(), service.LinkServiceConfig{}, zap.NewNop())
+	linkHandler := handler.NewLinkHandler(linkService, &stubClickProcessor{}, domains, handler.LinkHandlerConfig{}, zap.NewNop())
+	router := gin.New()
+	router.GET("/:code", linkHandler.Redirect)
+
+	code := "report1"
+	for _, domain := range []string{"", "go.example.com"} {
+		_, err := linkService.CreateLink(context.Background(), &models.CreateLinkInput{
+			OriginalURL: "https://files.example.com/q3.pdf",
+			CustomCode:  &code,
+			Domain:      domain,
+		})
+		require.NoError(t, err)
+	}
+
+	for host, action := range map[string]string{
+		"example.com":    "https://example.com/s/report1",
+		"go.example.com": "https://go.example.com/report1",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/report1+", nil)
+		req.Host = host
+		req.Header.Set("Accept", "text/html")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `action="`+action+`"`, host)
+	}
+}
+
+// TestLinkHandler_PreviewClickLimited проверяет, что предпросмотр не раскрывает адрес
+// одноразовой ссылки и не показывается после исчерпания лимита
+func TestLinkHandler_PreviewClickLimited(t *testing.T) {
+	router, linkService, clicks := setupLinkRouter(t, handler.LinkHandlerConfig{})
+	code := "invite1"
+	_, err := linkService.CreateLink(context.Background(), &models.CreateLinkInput{
+		OriginalURL: "https://files.example.com/invite",
+		CustomCode:  &code,
+		MaxClicks:   1,
+	})
+	require.NoError(t, err)
+
+	request := func(method, path, accept string, form url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Accept", accept)
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Адрес не показывается ни в HTML, ни в JSON
+	w := request("GET", "/invite1+", "text/html", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "https://files.example.com/invite")
+	w = request("GET", "/invite1+", "application/json", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "https://files.example.com/invite")
+
+	// «Continue» засчитывает единственный переход
+	w = request("POST", "/invite1", "text/html", url.Values{"continue": {"1"}})
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "https://files.example.com/invite", w.Header().Get("Location"))
+	assert.Equal(t, 1, clicks.clicks)
+
+	// После исчерпания предпросмотр отвечает 410
+	w = request("GET", "/invite1+", "application/json", nil)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), "link_exhausted")
+	w = request("GET", "/invite1+", "text/html", nil)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.NotContains(t, w.Body.String(), "https://files.example.com/invite")
+}
+
+// TestLinkHandler_GeoRules проверяет выбор адреса по стране посетителя и запись правила в клик
+func TestLinkHandler_GeoRules(t *testing.T) {
+	router, linkService, clicks := setupLinkRouter(t, handler.LinkHandlerConfig{
+		GeoIP: stubGeoIP{"10.0.0.1": "DE", "10.0.0.2": "FR", "10.0.0.3": "US"},
+	})
+	code := "store1"
+	_, err := linkService.CreateLink(context.Background(), &models.CreateLinkInput{
+		OriginalURL:  "https://store.example.com",
+		CustomCode:   &code,
+		RedirectType: models.RedirectPermanent,
+		GeoRules:     models.GeoRules{"de": "https://store.example.de", "FR": "https://store.example.fr"},
+	})
+	require.NoError(t, err)
+
+	for ip, want := range map[string]struct{ location, rule string }{
+		"10.0.0.1": {"https://store.example.de", "geo:DE"},
+		"10.0.0.2": {"https://store.example.fr", "geo:FR"},
+		"10.0.0.3": {"https://store.example.com", ""},
+		"10.0.0.9": {"https://store.example.com", ""}, // страна не определена
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/store1", nil)
+		req.RemoteAddr = ip + ":12345"
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code, ip)
+		assert.Equal(t, want.location, w.Header().Get("Location"), ip)
+		// Постоянный редирект с правилами не должен попасть в общий кэш
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"), ip)
+		require.NotNil(t, clicks.last)
+		assert.Equal(t, want.rule, clicks.last.Rule, ip)
+		assert.Equal(t, stubGeoIP{"10.0.0.1": "DE", "10.0.0.2": "FR", "10.0.0.3": "US"}[ip], clicks.last.Country, ip)
+	}
+}
+
+// TestLinkHandler_DeviceRules проверяет выбор адреса по платформе и страницу открытия приложения
+func TestLinkHandler_DeviceRules(t *testing.T) {
+	router, linkService, clicks := setupLinkRouter(t, handler.LinkHandlerConfig{})
+	code := "app1"
+	_, err := linkService.CreateLink(context.Background(), &models.CreateLinkInput{
+		OriginalURL: "https://app.example.com",
+		CustomCode:  &code,
+		DeviceRules: models.DeviceRules{
+			"ios":     {URL: "https://apps.apple.com/app/id123", DeepLink: "myapp://home?ref=link"},
+			"android": {URL: "https://play.google.com/store/apps/details?id=com.example"},
+		},
+	})
+	require.NoError(t, err)
+
+	const (
+		iPhone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+		android = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/124.0 Mobile Safari/537.36"
+		desktop = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/124.0 Safari/537.36"
+	)
+	follow := func(userAgent, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/app1", nil)
+		req.Header.Set("User-Agent", userAgent)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Android без deep link — редирект в магазин
+	w := follow(android, "")
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://play.google.com/store/apps/details?id=com.example", w.Header().Get("Location"))
+	assert.Equal(t, "device:android", clicks.last.Rule)
+
+	// Платформа без правила — адрес ссылки
+	w = follow(desktop, "")
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Location"))
+	assert.Empty(t, clicks.last.Rule)
+
+	// Браузер на iOS получает страницу, открывающую приложение, переход засчитывается
+	w = follow(iPhone, "text/html")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `href="myapp://home?ref=link"`)
+	assert.Contains(t, w.Body.String(), `https://apps.apple.com/app/id123`)
+	assert.Equal(t, "device:ios", clicks.last.Rule)
+
+	// API клиент на iOS получает редирект на запасной адрес
+	w = follow(iPhone, "application/json")
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://apps.apple.com/app/id123", w.Header().Get("Location"))
+	assert.Equal(t, 4, clicks.clicks)
+}
+
+// TestLinkHandler_Variants проверяет закрепление варианта A/B теста за посетителем через cookie
+func TestLinkHandler_Variants(t *testing.T) {
+	router, linkService, clicks := setupLinkRouter(t, handler.LinkHandlerConfig{})
+	code := "landing"
+	_, err := linkService.CreateLink(context.Background(), &models.CreateLinkInput{
+		OriginalURL:  "https://example.com/landing",
+		CustomCode:   &code,
+		RedirectType: models.RedirectPermanent,
+		Variants: []models.Variant{
+			{Name: "control", URL: "https://example.com/landing"},
+			{Name: "new", URL: "https://example.com/landing-v2"},
+		},
+	})
+	require.NoError(t, err)
+	destinations := map[string]string{"control": "https://example.com/landing", "new": "https://example.com/landing-v2"}
+
+	// Первый переход назначает вариант и запоминает его в cookie на путь ссылки
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/landing", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, handler.VariantCookie, cookies[0].Name)
+	assert.Equal(t, "/landing", cookies[0].Path)
+	assert.True(t, cookies[0].HttpOnly)
+	variant := cookies[0].Value
+	assert.Equal(t, destinations[variant], w.Header().Get("Location"))
+	assert.Equal(t, variant, clicks.last.Variant)
+
+	// С cookie посетитель остаётся в своём варианте, cookie не перевыставляется
+	other := map[string]string{"control": "new", "new": "control"}[variant]
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/landing", nil)
+	req.AddCookie(&http.Cookie{Name: handler.VariantCookie, Value: other})
+	router.ServeHTTP(w, req)
+	assert.Equal(t, destinations[other], w.Header().Get("Location"))
+	assert.Empty(t, w.Result().Cookies())
+	assert.Equal(t, other, clicks.last.Variant)
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// passwordPage форма ввода пароля защищённой ссылки. Форма без action отправляется
+// POST запросом на тот же адрес, поэтому работает на любом домене ссылок.
+var passwordPage = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+<style>
+body{font-family:system-ui,sans-serif;display:flex;justify-content:center;padding-top:15vh;margin:0;color:#222}
+form{display:flex;flex-direction:column;gap:.75rem;width:18rem}
+input,button{font:inherit;padding:.5rem}
+.error{color:#b00020}
+</style>
+</head>
+<body>
+<form method="post">
+<h1>Password required</h1>
+<p>This link is protected. Enter the password to continue.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<input type="password" name="password" autocomplete="current-password" required autofocus>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// previewPage страница предпросмотра: адрес назначения, заголовок и дата создания ссылки.
+// Адрес ссылки с лимитом переходов не показывается (Destination пустой).
+// Кнопка отправляет POST запрос на публичный адрес ссылки без «+» (Action), и только он
+// засчитывается как переход.
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link preview</title>
+<style>
+body{font-family:system-ui,sans-serif;display:flex;justify-content:center;padding-top:15vh;margin:0;color:#222}
+main{width:28rem}
+.url{word-break:break-all;font-family:ui-monospace,monospace;background:#f4f4f4;padding:.5rem}
+.meta{color:#666}
+button{font:inherit;padding:.5rem 1rem}
+</style>
+</head>
+<body>
+<main>
+<h1>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</h1>
+{{if .Destination}}<p>This link will take you to:</p>
+<p class="url">{{.Destination}}</p>{{else}}<p>This link can only be opened a limited number of times. The destination is revealed when you continue.</p>{{end}}
+{{if not .CreatedAt.IsZero}}<p class="meta">Created {{.CreatedAt.Format "2 January 2006"}}</p>{{end}}
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="continue" value="1">
+<button type="submit">Continue</button>
+</form>
+</main>
+</body>
+</html>
+`))
+
+// appPage страница открытия приложения: переходит по deep link и, если приложение
+// не открылось (страница осталась видимой), через 1,5 секунды уходит на адрес правила
+var appPage = template.Must(template.New("app").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Opening app</title>
+<style>
+body{font-family:system-ui,sans-serif;display:flex;justify-content:center;padding-top:15vh;margin:0;color:#222}
+main{width:24rem;text-align:center}
+</style>
+</head>
+<body>
+<main>
+<h1>Opening the app…</h1>
+<p><a href="{{.DeepLink}}">Open in the app</a></p>
+<p>Don't have the app? <a href="{{.Destination}}">Continue in the browser</a></p>
+</main>
+<script>
+(function () {
+  var fallback = setTimeout(function () { window.location.replace({{.Destination}}); }, 1500);
+  document.addEventListener("visibilitychange", function () {
+    if (document.hidden) { clearTimeout(fallback); }
+  });
+  window.location.href = {{.DeepLink}};
+})();
+</script>
+</body>
+</html>
+`))
+
+// Страницы состояния ссылки по умолчанию. Заменяются шаблонами из LinkHandlerConfig.
+var (
+	// notActivePage время активации ссылки ещё не наступило
+	notActivePage = statusPage("not-active", "Link is not active yet", "Not active yet",
+		"This link is not active yet. Please come back later.")
+	// notFoundPage ссылки с таким кодом нет
+	notFoundPage = statusPage("not-found", "Link not found", "Link not found",
+		"This link does not exist. Check the address and try again.")
+	// expiredPage срок действия ссылки истёк или исчерпан лимит переходов
+	expiredPage = statusPage("expired", "Link expired", "Link expired",
+		"This link has expired and is no longer available.")
+)
+
+// statusPage встроенная страница состояния ссылки с заголовком и одним абзацем текста
+func statusPage(name, title, heading, message string) *template.Template {
+	return template.Must(template.New(name).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>` + title + `</title>
+<style>
+body{font-family:system-ui,sans-serif;display:flex;justify-content:center;padding-top:15vh;margin:0;color:#222}
+main{width:24rem;text-align:center}
+</style>
+</head>
+<body>
+<main>
+<h1>` + heading + `</h1>
+<p>` + message + `</p>
+</main>
+</body>
+</html>
+`))
+}
+
+// linkPageData данные страниц состояния ссылки
+type linkPageData struct {
+	Domain string
+	Code   string
+}
+
+// ParsePageTemplate загружает HTML шаблон страницы из файла. В шаблоне доступны
+// {{.Domain}} и {{.Code}} — домен и короткий код запрошенной ссылки, в шаблоне
+// предпросмотра также {{.Title}}, {{.Destination}}, {{.CreatedAt}} и {{.Action}} (адрес
+// для кнопки «Continue»), в шаблоне
+// открытия приложения — {{.DeepLink}} и {{.Destination}}.
+func ParsePageTemplate(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page template: %w", err)
+	}
+	page, err := template.New(path).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page template %s: %w", path, err)
+	}
+	return page, nil
+}
+
+// previewPageData данные страницы предпросмотра
+type previewPageData struct {
+	linkPageData
+	Title       string
+	Destination string
+	CreatedAt   time.Time
+	// Action короткий адрес ссылки с учётом домена и пути базового URL (APP_BASE_URL)
+	Action string
+}
+
+// appPageData данные страницы открытия приложения. DeepLink проверен сервисом при
+// сохранении правила: без template.URL html/template заменил бы схему приложения на #ZgotmplZ.
+type appPageData struct {
+	linkPageData
+	DeepLink    template.URL
+	Destination string
+}
+
+// passwordPageData данные формы пароля
+type passwordPageData struct {
+	Error string
+}
+
+// renderStatus отвечает на запрос ссылки в её текущем состоянии (предпросмотр, не активна,
+// не найдена, истекла): HTML страницей для браузеров и JSON для остальных клиентов по
+// заголовку Accept
+func renderStatus(c *gin.Context, status int, page *template.Template, data any, body any) {
+	if acceptsHTML(c) {
+		renderPage(c, status, page, data)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, body)
+}
+
+// acceptsHTML клиент предпочитает HTML (браузер), а не JSON
+func acceptsHTML(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
+
+// renderPage отвечает HTML страницей. Страницы зависят от состояния ссылки
+// и не кэшируются.
+func renderPage(c *gin.Context, status int, page *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := page.Execute(&buf, data); err != nil {
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"fmt"
+
+	"github.com/SergeiKhy/url-shortener/internal/logging"
 	"github.com/SergeiKhy/url-shortener/internal/middleware"
 	"github.com/SergeiKhy/url-shortener/internal/service"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.uber.org/zap"
 )
 
@@ -12,25 +16,51 @@ func NewRouter(
 	clickProcessor service.ClickProcessor,
 	rateLimiter *middleware.RateLimiter,
 	apiKeyMiddleware gin.HandlerFunc,
+	health *HealthHandler,
+	domains *Domains,
+	trustedProxies []string,
+	linkConfig LinkHandlerConfig,
 	logger *zap.Logger,
-) *gin.Engine {
-	router := gin.Default()
-
-	// Middleware для логгирования
-	router.Use(func(c *gin.Context) {
-		logger.Info("Request",
-			zap.String("method", c.Request.Method),
-			zap.String("path", c.Request.URL.Path),
-			zap.String("ip", c.ClientIP()),
-		)
-		c.Next()
-	})
+) (*gin.Engine, error) {
+	// gin.Default() не используется: его логгер дублирует access-лог
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	// По умолчанию gin доверяет X-Forwarded-For от любого клиента, и подменой заголовка
+	// обходятся лимиты по IP (rate limiter, попытки пароля), геотаргетинг и закрепление
+	// варианта. Заголовок учитывается только от перечисленных прокси.
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	// Probe для балансировщика/оркестратора регистрируются до остальных middleware и не
+	// засоряют access-лог. Общий rate limiter к ним не применяется: балансировщик опрашивает
+	// инстанс с одного адреса, и 429 снял бы исправный инстанс с трафика. Нагрузку readiness
+	// на зависимости ограничивает сам HealthHandler.
+	router.GET("/livez", health.Livez)
+	router.GET("/readyz", health.Readyz)
+
+	// Трейсинг запросов (корневой спан, продолжает входящий traceparent)
+	router.Use(otelgin.Middleware("url-shortener"))
+
+	// ID запроса и access-лог после обработки запроса
+	router.Use(middleware.RequestID())
+	// Записи редиректа идут в отдельный логгер, который сэмплируется при высокой нагрузке
+	router.Use(middleware.NewAccessLog(middleware.AccessLogConfig{
+		Logger: logger,
+		RouteLoggers: map[string]*zap.Logger{
+			"/:code": logger.Named(logging.RedirectLoggerName),
+		},
+	}))
+
+	// Метрики HTTP запросов (до rate limiter, чтобы учитывать и отклонённые запросы)
+	router.Use(middleware.Metrics())
 
 	// Rate limiting для всех запросов
 	router.Use(rateLimiter.Middleware())
 
 	// Инициализация обработчика ссылок
-	linkHandler := NewLinkHandler(linkService, clickProcessor, logger)
+	linkHandler := NewLinkHandler(linkService, clickProcessor, domains, linkConfig, logger)
 
 	// API v.1
 	v1 := router.Group("/api/v1")
@@ -43,16 +73,19 @@ func NewRouter(
 		}
 
 		v1.POST("/links", linkHandler.CreateLink)
+		v1.PATCH("/links/:code", linkHandler.UpdateLink)
 		v1.DELETE("/links/:code", linkHandler.DeleteLink)
 		v1.GET("/links/:code/stats", linkHandler.GetStats)
 		v1.GET("/links/:code/stats/daily", linkHandler.GetDailyStats)
 	}
 
-	// Редирект (корневой путь) - без API key проверки
+	// Редирект (корневой путь) - без API key проверки.
+	// POST — отправка формы пароля защищённой ссылки
 	router.GET("/:code", linkHandler.Redirect)
+	router.POST("/:code", linkHandler.Redirect)
 
 	// Swagger документация (без аутентификации)
 	AddSwaggerRoutes(router)
 
-	return router
+	return router, nil
 }
@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// RedirectLoggerName имя логгера для высоконагруженного пути редиректа.
+// Только записи этого логгера (и его потомков) подвергаются сэмплированию.
+const RedirectLoggerName = "redirect"
+
+// Поддерживаемые форматы логов
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// New создаёт логгер по конфигурации. Возвращаемый AtomicLevel позволяет менять
+// уровень логирования во время работы.
+func New(cfg config.LogConfig) (*zap.Logger, zap.AtomicLevel, error) {
+	level := zap.NewAtomicLevel()
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, level, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+		}
+	}
+
+	encoder, err := newEncoder(cfg.Format)
+	if err != nil {
+		return nil, level, err
+	}
+
+	var core zapcore.Core = zapcore.NewCore(encoder, newWriteSyncer(cfg), level)
+
+	if cfg.SamplingInitial > 0 {
+		core = &namedSamplerCore{
+			Core: core,
+			sampled: zapcore.NewSamplerWithOptions(core, time.Second,
+				cfg.SamplingInitial, cfg.SamplingThereafter),
+			name: RedirectLoggerName,
+		}
+	}
+
+	logger := zap.New(core,
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	)
+
+	return logger, level, nil
+}
+
+// newEncoder создаёт энкодер JSON (для продакшена) или console (для локальной разработки)
+func newEncoder(format string) (zapcore.Encoder, error) {
+	switch format {
+	case "", FormatJSON:
+		encoderConfig := zap.NewProductionEncoderConfig()
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		return zapcore.NewJSONEncoder(encoderConfig), nil
+	case FormatConsole:
+		encoderConfig := zap.NewDevelopmentEncoderConfig()
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		return zapcore.NewConsoleEncoder(encoderConfig), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// newWriteSyncer возвращает stdout или файл с ротацией, если он задан
+func newWriteSyncer(cfg config.LogConfig) zapcore.WriteSyncer {
+	if cfg.File == "" {
+		return zapcore.Lock(os.Stdout)
+	}
+
+	return zapcore.AddSync(&lumberjack.Logger{
+		Filename:   cfg.File,
+		MaxSize:    cfg.FileMaxSizeMB,
+		MaxBackups: cfg.FileMaxBackups,
+		MaxAge:     cfg.FileMaxAgeDays,
+		Compress:   true,
+	})
+}
+
+// namedSamplerCore сэмплирует записи только указанного логгера,
+// остальные записи (ошибки API, события воркеров) пишутся без потерь
+type namedSamplerCore struct {
+	zapcore.Core
+	sampled zapcore.Core
+	name    string
+}
+
+func (c *namedSamplerCore) With(fields []zapcore.Field) zapcore.Core {
+	return &namedSamplerCore{
+		Core:    c.Core.With(fields),
+		sampled: c.sampled.With(fields), // счётчики сэмплера общие для всех потомков
+		name:    c.name,
+	}
+}
+
+func (c *namedSamplerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.LoggerName == c.name || strings.HasPrefix(ent.LoggerName, c.name+".") {
+		return c.sampled.Check(ent, ce)
+	}
+	return c.Core.Check(ent, ce)
+}
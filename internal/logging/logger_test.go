@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestNamedSamplerCore проверяет, что сэмплируются только записи логгера редиректа
+func TestNamedSamplerCore(t *testing.T) {
+	observed, logs := observer.New(zapcore.DebugLevel)
+	core := &namedSamplerCore{
+		Core:    observed,
+		sampled: zapcore.NewSamplerWithOptions(observed, time.Minute, 2, 0),
+		name:    RedirectLoggerName,
+	}
+	logger := zap.New(core)
+
+	redirectLogger := logger.Named(RedirectLoggerName).With(zap.String("request_id", "r1"))
+	for i := 0; i < 10; i++ {
+		redirectLogger.Info("HTTP request")
+		logger.Info("HTTP request")
+	}
+
+	assert.Equal(t, 2, logs.FilterLoggerName(RedirectLoggerName).Len(), "записи редиректа должны сэмплироваться")
+	assert.Equal(t, 10, logs.FilterLoggerName("").Len(), "остальные записи не должны теряться")
+}
+
+// TestNewEncoder_UnknownFormat проверяет отказ на неизвестный формат логов
+func TestNewEncoder_UnknownFormat(t *testing.T) {
+	_, err := newEncoder("xml")
+	assert.Error(t, err)
+
+	for _, format := range []string{"", FormatJSON, FormatConsole} {
+		_, err := newEncoder(format)
+		assert.NoError(t, err, format)
+	}
+}
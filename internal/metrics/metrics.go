@@ -0,0 +1,208 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace общий префикс для всех метрик сервиса
+const namespace = "url_shortener"
+
+var (
+	// HTTPRequests количество HTTP запросов по роуту, методу и статусу
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPRequestDuration латентность HTTP запросов по роуту, методу и статусу
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"route", "method", "status"})
+
+	// RedirectCacheRequests попадания, negative-попадания и промахи кэша в LinkService.GetLink
+	RedirectCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirect_cache_requests_total",
+		Help:      "Link cache lookups on the redirect path by result (hit, negative_hit or miss).",
+	}, []string{"result"})
+
+	// LinkLookupsCoalesced запросы ссылки, объединённые с уже выполняющимся запросом в БД
+	LinkLookupsCoalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "link_lookups_coalesced_total",
+		Help:      "Link lookups that shared an in-flight database query for the same code.",
+	})
+
+	// LocalCacheRequests попадания и промахи локального (in-process) кэша ссылок
+	LocalCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "local_cache_requests_total",
+		Help:      "In-process link cache lookups by result (hit or miss).",
+	}, []string{"result"})
+
+	// LocalCacheInvalidations записи, удалённые из локального кэша по сообщению pub/sub
+	LocalCacheInvalidations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "local_cache_invalidations_total",
+		Help:      "In-process link cache invalidations received over Redis pub/sub.",
+	})
+
+	// CacheWarmupLinks ссылки, обработанные прогревом кэша, по результату
+	CacheWarmupLinks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_warmup_links_total",
+		Help:      "Links processed by cache warm-up by result (loaded, failed or skipped).",
+	}, []string{"result"})
+
+	// ClicksDropped количество кликов, потерянных из-за заполненного буфера
+	ClicksDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clicks_dropped_total",
+		Help:      "Click events dropped because the click channel was full.",
+	})
+
+	// CacheCircuitOpen состояние circuit breaker кэша (1 — Redis отключён)
+	CacheCircuitOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_circuit_open",
+		Help:      "Whether the cache circuit breaker is open (1) or closed (0).",
+	})
+
+	// CacheCircuitRejections операции с кэшем, пропущенные из-за разомкнутого breaker
+	CacheCircuitRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_circuit_rejections_total",
+		Help:      "Cache operations skipped because the circuit breaker was open.",
+	})
+
+	// CacheInvalidationsPending удаления из кэша, отложенные до восстановления Redis
+	CacheInvalidationsPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_invalidations_pending",
+		Help:      "Cache deletions deferred until Redis is reachable again.",
+	})
+
+	// DBReads запросы чтения по месту выполнения (реплика или primary)
+	DBReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_reads_total",
+		Help:      "Read queries by target (replica or primary).",
+	}, []string{"target"})
+
+	// DBReplicaUp доступность реплик БД (1 — используется для чтения)
+	DBReplicaUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_replica_up",
+		Help:      "Whether a read replica is healthy and receives reads (1) or not (0).",
+	}, []string{"replica"})
+
+	// ClickPartitionOps созданные и отсоединённые партиции таблицы кликов
+	ClickPartitionOps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "click_partition_operations_total",
+		Help:      "Click table partitions created, dropped or archived by the partition manager.",
+	}, []string{"op"})
+
+	// ExpiredLinksPurged истёкшие ссылки, удалённые или перенесённые в архив фоновой очисткой
+	ExpiredLinksPurged = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "expired_links_purged_total",
+		Help:      "Expired links removed from the links table by the reaper.",
+	})
+
+	// LinkPasswordChecks проверки пароля защищённых ссылок по результату
+	LinkPasswordChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "link_password_checks_total",
+		Help:      "Password checks on protected links by result (valid, invalid or limited).",
+	}, []string{"result"})
+
+	// ExhaustedLinkRedirects переходы, отклонённые из-за исчерпанного лимита переходов ссылки
+	ExhaustedLinkRedirects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exhausted_link_redirects_total",
+		Help:      "Redirects refused because the link reached its click limit.",
+	})
+
+	// ExpiredLinkRedirects переходы по истёкшим и исчерпанным ссылкам: на fallback URL или ответ 410
+	ExpiredLinkRedirects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "expired_link_redirects_total",
+		Help:      "Requests to expired or exhausted links by result (fallback or gone).",
+	}, []string{"result"})
+
+	// LinkPreviews страницы предпросмотра, показанные вместо редиректа
+	LinkPreviews = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "link_previews_total",
+		Help:      "Preview pages shown instead of redirecting.",
+	})
+
+	// RateLimitRejections количество запросов, отклонённых rate limiter
+	RateLimitRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by the rate limiter.",
+	})
+)
+
+// Значения label result для метрик кэша
+const (
+	ResultHit  = "hit"
+	ResultMiss = "miss"
+	// ResultNegativeHit в кэше записано, что ссылки нет
+	ResultNegativeHit = "negative_hit"
+)
+
+// Значения label result для прогрева кэша
+const (
+	WarmupLoaded = "loaded"
+	WarmupFailed = "failed"
+	// WarmupSkipped ссылку удалили после выбора популярных ссылок
+	WarmupSkipped = "skipped"
+)
+
+// Значения label target для чтения из БД
+const (
+	DBTargetPrimary = "primary"
+	DBTargetReplica = "replica"
+)
+
+// Значения label op для партиций кликов
+const (
+	PartitionCreated  = "created"
+	PartitionDropped  = "dropped"
+	PartitionArchived = "archived"
+)
+
+// Значения label result для проверок пароля ссылки
+const (
+	PasswordValid   = "valid"
+	PasswordInvalid = "invalid"
+	// PasswordLimited проверка не выполнялась: исчерпан лимит неудачных попыток
+	PasswordLimited = "limited"
+)
+
+// Значения label result для переходов по истёкшим ссылкам
+const (
+	ExpiredFallback = "fallback"
+	ExpiredGone     = "gone"
+)
+
+// Handler возвращает HTTP handler для экспорта метрик
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// MustRegister регистрирует дополнительные коллекторы (пулы соединений, очередь кликов)
+func MustRegister(collectors ...prometheus.Collector) {
+	prometheus.MustRegister(collectors...)
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AccessLogConfig конфигурация access-лога
+type AccessLogConfig struct {
+	// Logger логгер по умолчанию
+	Logger *zap.Logger
+	// RouteLoggers логгеры для отдельных роутов (шаблон роута -> логгер),
+	// например, сэмплируемый логгер для высоконагруженного редиректа
+	RouteLoggers map[string]*zap.Logger
+}
+
+// AccessLog возвращает middleware, который пишет access-лог после обработки запроса
+func AccessLog(logger *zap.Logger) gin.HandlerFunc {
+	return NewAccessLog(AccessLogConfig{Logger: logger})
+}
+
+// NewAccessLog создаёт access-лог middleware с конфигурацией
+func NewAccessLog(config AccessLogConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0 // тело ответа не записывалось
+		}
+		fields := []zap.Field{
+			zap.String("request_id", GetRequestID(c)),
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("route", c.FullPath()),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.Int("bytes", size),
+			zap.String("ip", c.ClientIP()),
+		}
+
+		if keyName := c.GetString("api_key_name"); keyName != "" {
+			fields = append(fields, zap.String("api_key_name", keyName))
+		}
+		if code := c.Param("code"); code != "" {
+			fields = append(fields, zap.String("short_code", code))
+		}
+		if spanCtx := trace.SpanContextFromContext(c.Request.Context()); spanCtx.HasTraceID() {
+			fields = append(fields, zap.String("trace_id", spanCtx.TraceID().String()))
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		logger := config.Logger
+		if routeLogger, ok := config.RouteLoggers[c.FullPath()]; ok {
+			logger = routeLogger
+		}
+		logger.Log(accessLogLevel(status), "HTTP request", fields...)
+	}
+}
+
+// accessLogLevel выбирает уровень записи по статусу ответа
+func accessLogLevel(status int) zapcore.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return zapcore.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute метка роута для запросов, не попавших ни в один роут
+const unmatchedRoute = "unmatched"
+
+// Metrics возвращает Gin middleware для сбора метрик HTTP запросов
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		// Используем шаблон роута, а не реальный путь, чтобы не раздувать кардинальность
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HTTPRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, c.Request.Method, status).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -3,12 +3,21 @@ package middleware_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/SergeiKhy/url-shortener/internal/logging"
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
 	"github.com/SergeiKhy/url-shortener/internal/middleware"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 // TestRateLimiter_Middleware проверяет работу rate limiter middleware
@@ -89,6 +98,46 @@ func TestRateLimiter_MiddlewareWithKey(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+// TestRateLimiter_Reserve проверяет, что параллельные резервирования не превышают лимит,
+// а возвращённое событие можно использовать снова
+func TestRateLimiter_Reserve(t *testing.T) {
+	rl := middleware.NewRateLimiter(middleware.RateLimiterConfig{
+		RequestsPerSecond: 0.001,
+		BurstSize:         3,
+		CleanupInterval:   time.Minute,
+	})
+
+	var (
+		wg       sync.WaitGroup
+		reserved atomic.Int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := rl.Reserve("key"); ok {
+				reserved.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(3), reserved.Load())
+
+	_, ok := rl.Reserve("key")
+	assert.False(t, ok)
+
+	// Другой ключ не затронут; возвращённое событие снова доступно
+	release, ok := rl.Reserve("other")
+	require.True(t, ok)
+	release()
+	for i := 0; i < 3; i++ {
+		_, ok := rl.Reserve("other")
+		assert.True(t, ok)
+	}
+	_, ok = rl.Reserve("other")
+	assert.False(t, ok)
+}
+
 // TestAPIKey_Middleware проверяет аутентификацию по API ключу
 func TestAPIKey_Middleware(t *testing.T) {
 	gin.SetMode(gin.TestMode)
@@ -223,3 +272,122 @@ func TestAPIKey_Middleware_BearerToken(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+// TestMetrics_Middleware проверяет сбор метрик HTTP запросов по шаблону роута
+func TestMetrics_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middleware.Metrics())
+	router.GET("/items/:id", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	counter := metrics.HTTPRequests.WithLabelValues("/items/:id", "GET", "200")
+	before := testutil.ToFloat64(counter)
+
+	// Разные пути одного роута должны попадать в одну серию
+	for _, path := range []string{"/items/1", "/items/2"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+}
+
+// TestRateLimiter_RejectionsMetric проверяет учёт отклонённых запросов в метриках
+func TestRateLimiter_RejectionsMetric(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	rl := middleware.NewRateLimiter(middleware.RateLimiterConfig{
+		RequestsPerSecond: 1,
+		BurstSize:         1,
+		CleanupInterval:   time.Minute,
+	})
+
+	router := gin.New()
+	router.Use(rl.Middleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	before := testutil.ToFloat64(metrics.RateLimitRejections)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		router.ServeHTTP(w, req)
+	}
+
+	assert.Equal(t, before+2, testutil.ToFloat64(metrics.RateLimitRejections))
+}
+
+// TestRequestID_Middleware проверяет генерацию и проброс X-Request-ID
+func TestRequestID_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.GET("/test", func(c *gin.Context) {
+		// ID запроса должен быть доступен сервисному слою через context.Context
+		c.String(http.StatusOK, logging.RequestIDFromContext(c.Request.Context()))
+	})
+
+	// Без заголовка ID генерируется
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	router.ServeHTTP(w, req)
+	generated := w.Header().Get(middleware.RequestIDHeader)
+	assert.Len(t, generated, 32)
+	assert.Equal(t, generated, w.Body.String())
+
+	// Переданный клиентом ID сохраняется
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/test", nil)
+	req.Header.Set(middleware.RequestIDHeader, "upstream-id-42")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "upstream-id-42", w.Header().Get(middleware.RequestIDHeader))
+	assert.Equal(t, "upstream-id-42", w.Body.String())
+
+	// Невалидный ID заменяется сгенерированным
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/test", nil)
+	req.Header.Set(middleware.RequestIDHeader, "bad id\twith spaces")
+	router.ServeHTTP(w, req)
+	assert.Len(t, w.Header().Get(middleware.RequestIDHeader), 32)
+}
+
+// TestAccessLog_Middleware проверяет, что access-лог пишется после обработки запроса со статусом
+func TestAccessLog_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.Use(middleware.AccessLog(logger))
+	router.GET("/:code", func(c *gin.Context) {
+		c.Set("api_key_name", "Test Key")
+		c.String(http.StatusNotFound, "missing")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/abc123", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	assert.Equal(t, zapcore.WarnLevel, entry.Level)
+
+	fields := entry.ContextMap()
+	assert.Equal(t, "req-1", fields["request_id"])
+	assert.Equal(t, int64(http.StatusNotFound), fields["status"])
+	assert.Equal(t, int64(len("missing")), fields["bytes"])
+	assert.Equal(t, "Test Key", fields["api_key_name"])
+	assert.Equal(t, "abc123", fields["short_code"])
+	assert.Equal(t, "/:code", fields["route"])
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 )
@@ -91,6 +92,23 @@ func (rl *RateLimiter) getLimiter(ip string) *rate.Limiter {
 	return limiter
 }
 
+// Reserve атомарно расходует одно событие из лимита ключа и возвращает false, если лимит
+// исчерпан. Используется, когда лимит ограничивает не все запросы, а только неудачные
+// (например, попытки ввода пароля): событие расходуется до проверки, а release возвращает
+// его в лимит, если попытка оказалась удачной. Так параллельные запросы не проходят
+// проверку сверх лимита.
+func (rl *RateLimiter) Reserve(key string) (release func(), ok bool) {
+	now := time.Now()
+	reservation := rl.getLimiter(key).ReserveN(now, 1)
+	if !reservation.OK() || reservation.DelayFrom(now) > 0 {
+		reservation.CancelAt(now)
+		return nil, false
+	}
+	// Cancel возвращает событие, только пока время резервирования не наступило,
+	// поэтому отмена выполняется на момент резервирования
+	return func() { reservation.CancelAt(now) }, true
+}
+
 // Middleware возвращает Gin middleware handler для rate limiting
 func (rl *RateLimiter) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -98,6 +116,7 @@ func (rl *RateLimiter) Middleware() gin.HandlerFunc {
 		limiter := rl.getLimiter(ip)
 
 		if !limiter.Allow() {
+			metrics.RateLimitRejections.Inc()
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       "rate_limit_exceeded",
 				"message":     "Слишком много запросов, попробуйте позже",
@@ -121,6 +140,7 @@ func (rl *RateLimiter) MiddlewareWithKey(getKey func(*gin.Context) string) gin.H
 		limiter := rl.getLimiter(key)
 
 		if !limiter.Allow() {
+			metrics.RateLimitRejections.Inc()
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       "rate_limit_exceeded",
 				"message":     "Слишком много запросов, попробуйте позже",
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/SergeiKhy/url-shortener/internal/logging"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader заголовок для передачи ID запроса
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength максимальная длина принимаемого от клиента ID запроса
+const maxRequestIDLength = 128
+
+// RequestID возвращает middleware, который берёт ID запроса из заголовка X-Request-ID
+// или генерирует новый, возвращает его в ответе и кладёт в контекст запроса
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+
+		// Позволяет найти трейс по ID запроса
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request.id", requestID))
+
+		c.Next()
+	}
+}
+
+// GetRequestID извлекает ID запроса из контекста Gin
+func GetRequestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// isValidRequestID проверяет ID запроса от клиента: ограниченная длина и только печатные ASCII символы,
+// чтобы клиент не мог подделать строки в логах
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID генерирует случайный ID запроса (128 бит в hex)
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,55 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeiKhy/url-shortener/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoad проверяет разбор файлов и сортировку по версии
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_add_column.sql": {Data: []byte("-- +migrate Up\nALTER TABLE t ADD COLUMN c INT;\n\n-- +migrate Down\nALTER TABLE t DROP COLUMN c;\n")},
+		"000001_init.sql":       {Data: []byte("-- +migrate Up\nCREATE TABLE t (id INT);\n-- +migrate Down\nDROP TABLE t;\n")},
+		"README.md":             {Data: []byte("не миграция")},
+	}
+
+	migrations, err := Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "init", migrations[0].Name)
+	assert.Equal(t, "CREATE TABLE t (id INT);", migrations[0].Up)
+	assert.Equal(t, "DROP TABLE t;", migrations[0].Down)
+
+	assert.Equal(t, int64(2), migrations[1].Version)
+	assert.Equal(t, "ALTER TABLE t DROP COLUMN c;", migrations[1].Down)
+}
+
+// TestLoad_Errors проверяет отказ на дублях версий и файлах без секции Up
+func TestLoad_Errors(t *testing.T) {
+	_, err := Load(fstest.MapFS{
+		"000001_a.sql": {Data: []byte("-- +migrate Up\nSELECT 1;")},
+		"1_b.sql":      {Data: []byte("-- +migrate Up\nSELECT 1;")},
+	})
+	assert.ErrorContains(t, err, "duplicate migration version 1")
+
+	_, err = Load(fstest.MapFS{
+		"000001_a.sql": {Data: []byte("SELECT 1;")},
+	})
+	assert.ErrorContains(t, err, "missing")
+}
+
+// TestLoad_Embedded проверяет, что встроенные миграции разбираются
+func TestLoad_Embedded(t *testing.T) {
+	migrations, err := Load(migration.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for _, m := range migrations {
+		assert.NotEmpty(t, m.Down, "migration %d_%s", m.Version, m.Name)
+	}
+}
@@ -2,34 +2,64 @@ package models
 
 import (
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Click struct {
-	ID        int64      `json:"id"`
-	LinkID    int64      `json:"link_id"`
-	ShortCode string     `json:"short_code"`
-	IPAddress string     `json:"ip_address"`
-	UserAgent string     `json:"user_agent"`
-	Referer   string     `json:"referer"`
-	Country   string     `json:"country"`
-	ClickedAt time.Time  `json:"clicked_at"`
+	ID        int64  `json:"id"`
+	LinkID    int64  `json:"link_id"`
+	ShortCode string `json:"short_code"`
+	IPAddress string `json:"ip_address"`
+	UserAgent string `json:"user_agent"`
+	Referer   string `json:"referer"`
+	Country   string `json:"country"`
+	// Rule правило, по которому выбран адрес перехода, пустое — адрес по умолчанию
+	Rule string `json:"rule,omitempty"`
+	// Variant вариант A/B теста, на который ушёл переход
+	Variant   string    `json:"variant,omitempty"`
+	ClickedAt time.Time `json:"clicked_at"`
 }
 
 type ClickEvent struct {
+	Domain    string
 	ShortCode string
 	IPAddress string
 	UserAgent string
 	Referer   string
 	Country   string
+	Rule      string
+	Variant   string
+	// RequestID ID запроса редиректа для корреляции логов асинхронной обработки
+	RequestID string
+	// SpanContext спан запроса редиректа, на который ссылается спан обработки клика
+	SpanContext trace.SpanContext
 }
 
 type ClickStats struct {
 	ShortCode    string `json:"short_code"`
 	TotalClicks  int64  `json:"total_clicks"`
 	UniqueClicks int64  `json:"unique_clicks"`
+	// Variants клики по вариантам A/B теста, включая варианты, уже удалённые из ссылки
+	Variants []VariantClickStats `json:"variants,omitempty"`
+}
+
+// VariantClickStats клики одного варианта A/B теста
+type VariantClickStats struct {
+	Variant      string `json:"variant"`
+	TotalClicks  int64  `json:"total_clicks"`
+	UniqueClicks int64  `json:"unique_clicks"`
 }
 
 type DailyClickStats struct {
-	Date  string `json:"date"`
+	Date   string `json:"date"`
 	Clicks int64  `json:"clicks"`
+	// Variants клики за день по вариантам A/B теста
+	Variants map[string]int64 `json:"variants,omitempty"`
+}
+
+// ClickPartition помесячная партиция таблицы clicks
+type ClickPartition struct {
+	Name  string
+	Month time.Time // начало месяца; клики хранятся в локальном времени сервера без часового пояса
 }
@@ -1,21 +1,190 @@
 package models
 
 import (
+	"net/http"
+	"strings"
 	"time"
 )
 
+// Типы редиректа — HTTP статус ответа на переход по короткой ссылке
+const (
+	RedirectMovedPermanently = http.StatusMovedPermanently  // 301
+	RedirectFound            = http.StatusFound             // 302
+	RedirectTemporary        = http.StatusTemporaryRedirect // 307
+	RedirectPermanent        = http.StatusPermanentRedirect // 308
+	DefaultRedirectType      = RedirectTemporary
+)
+
 type Link struct {
-	ID          int64      `json:"id"`
-	ShortCode   string     `json:"short_code"`
-	OriginalURL string     `json:"original_url"`
-	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
+	ID int64 `json:"id"`
+	// Domain домен короткой ссылки, пустой — домен по умолчанию (APP_BASE_URL)
+	Domain      string `json:"domain,omitempty"`
+	ShortCode   string `json:"short_code"`
+	OriginalURL string `json:"original_url"`
+	// Title заголовок ссылки, показывается на странице предпросмотра
+	Title string `json:"title,omitempty"`
+	// Preview переход по ссылке всегда начинается со страницы предпросмотра
+	Preview bool `json:"preview,omitempty"`
+	// RedirectType HTTP статус редиректа (301, 302, 307, 308)
+	RedirectType int `json:"redirect_type"`
+	// PasswordHash bcrypt хэш пароля, пустой — ссылка без пароля
+	PasswordHash string `json:"-"`
+	// MaxClicks максимум переходов по ссылке, 0 — без ограничения
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// UsedClicks засчитанные переходы ссылки с ограничением (в кэше не хранится)
+	UsedClicks int `json:"used_clicks,omitempty"`
+	// GeoRules адреса назначения по стране посетителя, для остальных стран — OriginalURL
+	GeoRules GeoRules `json:"geo_rules,omitempty"`
+	// DeviceRules адреса назначения по платформе посетителя, проверяются раньше GeoRules
+	DeviceRules DeviceRules `json:"device_rules,omitempty"`
+	// Variants варианты A/B теста: делят трафик, не попавший под правила, вместо OriginalURL
+	Variants []Variant `json:"variants,omitempty"`
+	// FallbackURL адрес, на который ведёт ссылка после истечения, пустой — ответ 410
+	FallbackURL string `json:"fallback_url,omitempty"`
+	// StartsAt время активации, до него ссылка не открывается
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// HasPassword переход по ссылке требует пароль
+func (l *Link) HasPassword() bool {
+	return l.PasswordHash != ""
+}
+
+// RedirectStatus статус редиректа ссылки. Записи кэша, сохранённые до появления
+// типа редиректа, не содержат его — для них сохраняется прежнее поведение (307).
+func (l *Link) RedirectStatus() int {
+	if l.RedirectType == 0 {
+		return DefaultRedirectType
+	}
+	return l.RedirectType
+}
+
+// ClickLimited число переходов по ссылке ограничено
+func (l *Link) ClickLimited() bool {
+	return l.MaxClicks > 0
+}
+
+// HasRules адрес назначения зависит от посетителя
+func (l *Link) HasRules() bool {
+	return len(l.GeoRules) > 0 || len(l.DeviceRules) > 0 || len(l.Variants) > 0
+}
+
+// Target адрес перехода, выбранный для посетителя
+type Target struct {
+	URL string
+	// DeepLink ссылка в приложение, URL для неё — запасной адрес
+	DeepLink string
+	// Rule имя сработавшего правила, пустое — адрес ссылки по умолчанию
+	Rule string
+	// Variant имя выбранного варианта A/B теста, пустое — ссылка без вариантов или сработало правило
+	Variant string
+}
+
+// Target выбирает адрес назначения для посетителя: правило платформы важнее правила
+// страны, остальной трафик делится между вариантами, иначе — OriginalURL
+func (l *Link) Target(visitor Visitor) Target {
+	if rule, ok := l.DeviceRules[visitor.Platform]; ok {
+		return Target{URL: rule.URL, DeepLink: rule.DeepLink, Rule: DeviceRulePrefix + visitor.Platform}
+	}
+	if destination, ok := l.GeoRules[visitor.Country]; ok {
+		return Target{URL: destination, Rule: GeoRulePrefix + visitor.Country}
+	}
+	if variant, ok := l.chooseVariant(visitor); ok {
+		return Target{URL: variant.URL, Variant: variant.Name}
+	}
+	return Target{URL: l.OriginalURL}
+}
+
+// NotStarted ссылка ещё не активна в момент now
+func (l *Link) NotStarted(now time.Time) bool {
+	return l.StartsAt != nil && now.Before(*l.StartsAt)
+}
+
+// Expired срок действия ссылки истёк к моменту now
+func (l *Link) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// ValidRedirectType проверяет, что статус можно использовать как тип редиректа
+func ValidRedirectType(status int) bool {
+	switch status {
+	case RedirectMovedPermanently, RedirectFound, RedirectTemporary, RedirectPermanent:
+		return true
+	}
+	return false
+}
+
+// IsPermanentRedirect постоянный редирект, который браузеры и поисковики кэшируют
+func IsPermanentRedirect(status int) bool {
+	return status == RedirectMovedPermanently || status == RedirectPermanent
+}
+
+// GeoRules правила геотаргетинга: ISO 3166-1 alpha-2 код страны → адрес назначения
+type GeoRules map[string]string
+
+// GeoRulePrefix префикс имени правила геотаргетинга в статистике кликов («geo:DE»)
+const GeoRulePrefix = "geo:"
+
+// Visitor данные посетителя, по которым выбирается адрес назначения ссылки
+type Visitor struct {
+	// Country ISO код страны по GeoIP, пустой — страна не определена
+	Country string
+	// Platform платформа по User-Agent (ParsePlatform), пустая — не определена
+	Platform string
+	// Variant вариант A/B теста, назначенный посетителю раньше (из cookie)
+	Variant string
+	// Fingerprint отпечаток посетителя без cookie для выбора варианта (IP и User-Agent)
+	Fingerprint string
 }
 
 type CreateLinkInput struct {
-	OriginalURL string  `json:"original_url" binding:"required,url"`
-	ExpiresIn   *int    `json:"expires_in,omitempty"`
-	CustomCode  *string `json:"custom_code,omitempty"`
+	OriginalURL string `json:"original_url" binding:"required,url"`
+	// ExpiresIn срок действия в минутах, ExpiresAt — абсолютное время истечения (взаимоисключающие)
+	ExpiresIn *int       `json:"expires_in,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// StartsAt время активации ссылки
+	StartsAt   *time.Time `json:"starts_at,omitempty"`
+	CustomCode *string    `json:"custom_code,omitempty"`
+	Domain     string     `json:"domain,omitempty"`
+	// RedirectType 0 — тип редиректа сервиса по умолчанию
+	RedirectType int `json:"redirect_type,omitempty"`
+	// Password пароль для перехода по ссылке, хранится только bcrypt хэш
+	Password string `json:"password,omitempty"`
+	// MaxClicks максимум переходов (1 — одноразовая ссылка), 0 — без ограничения
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// FallbackURL адрес для переходов после истечения ссылки
+	FallbackURL string `json:"fallback_url,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Preview     bool   `json:"preview,omitempty"`
+	// GeoRules адреса назначения по стране посетителя
+	GeoRules GeoRules `json:"geo_rules,omitempty"`
+	// DeviceRules адреса назначения по платформе посетителя
+	DeviceRules DeviceRules `json:"device_rules,omitempty"`
+	// Variants варианты A/B теста с весами
+	Variants []Variant `json:"variants,omitempty"`
+}
+
+// UpdateLinkInput изменяемые поля ссылки, nil — поле не меняется
+type UpdateLinkInput struct {
+	RedirectType *int `json:"redirect_type,omitempty"`
+	// Password новый пароль, пустая строка снимает защиту
+	Password *string `json:"password,omitempty"`
+	// PasswordHash хэш нового пароля, заполняется сервисом для репозитория
+	PasswordHash *string `json:"-"`
+	// MaxClicks новый лимит переходов, 0 снимает ограничение
+	MaxClicks *int `json:"max_clicks,omitempty"`
+	// FallbackURL новый адрес после истечения, пустая строка убирает его
+	FallbackURL *string `json:"fallback_url,omitempty"`
+	Title       *string `json:"title,omitempty"`
+	Preview     *bool   `json:"preview,omitempty"`
+	// GeoRules новые правила геотаргетинга целиком, пустой объект удаляет их
+	GeoRules GeoRules `json:"geo_rules,omitempty"`
+	// DeviceRules новые правила по устройству целиком, пустой объект удаляет их
+	DeviceRules DeviceRules `json:"device_rules,omitempty"`
+	// Variants новые варианты A/B теста целиком, пустой список завершает тест
+	Variants []Variant `json:"variants,omitempty"`
 }
 
 type LinkStats struct {
@@ -23,3 +192,20 @@ type LinkStats struct {
 	Clicks       int64  `json:"clicks"`
 	UniqueClicks int64  `json:"unique_clicks"`
 }
+
+// LinkKey ключ ссылки в кэше: код для домена по умолчанию, «домен/код» для остальных.
+// Коды и домены не содержат «/», поэтому ключ разбирается однозначно.
+func LinkKey(domain, code string) string {
+	if domain == "" {
+		return code
+	}
+	return domain + "/" + code
+}
+
+// SplitLinkKey разбирает ключ, построенный LinkKey
+func SplitLinkKey(key string) (domain, code string) {
+	if domain, code, ok := strings.Cut(key, "/"); ok {
+		return domain, code
+	}
+	return "", key
+}
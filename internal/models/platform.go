@@ -0,0 +1,53 @@
+package models
+
+import "strings"
+
+// Платформы посетителя для правил по устройству
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformDesktop = "desktop"
+)
+
+// DeviceRulePrefix префикс имени правила по устройству в статистике кликов («device:ios»)
+const DeviceRulePrefix = "device:"
+
+// DeviceRule адрес назначения для платформы
+type DeviceRule struct {
+	// URL адрес магазина приложений или сайта
+	URL string `json:"url"`
+	// DeepLink ссылка в приложение (myapp://product/42). Если задана, посетитель получает
+	// страницу, которая открывает приложение, а без него через секунду переходит на URL.
+	DeepLink string `json:"deep_link,omitempty"`
+}
+
+// DeviceRules правила по устройству: платформа (ios, android, desktop) → адрес назначения
+type DeviceRules map[string]DeviceRule
+
+// ValidPlatform платформа, для которой можно задать правило
+func ValidPlatform(platform string) bool {
+	switch platform {
+	case PlatformIOS, PlatformAndroid, PlatformDesktop:
+		return true
+	}
+	return false
+}
+
+// ParsePlatform определяет платформу по заголовку User-Agent. iPadOS 13+ по умолчанию
+// представляется как macOS и попадает в desktop. Пустой User-Agent (боты, curl без
+// заголовка) платформу не определяет.
+func ParsePlatform(userAgent string) string {
+	switch {
+	case userAgent == "":
+		return ""
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return PlatformIOS
+	case strings.Contains(userAgent, "Android"):
+		return PlatformAndroid
+	case strings.Contains(userAgent, "Mobile"):
+		// Прочие мобильные платформы не получают ни магазин, ни сайт для компьютера
+		return ""
+	default:
+		return PlatformDesktop
+	}
+}
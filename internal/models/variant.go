@@ -0,0 +1,46 @@
+package models
+
+import (
+	"hash/fnv"
+	"strconv"
+)
+
+// Variant вариант адреса назначения в A/B тесте ссылки
+type Variant struct {
+	// Name имя варианта в статистике кликов
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Weight доля трафика варианта относительно суммы весов всех вариантов
+	Weight int `json:"weight"`
+}
+
+// chooseVariant выбирает вариант для посетителя. Вариант из cookie сохраняется, пока
+// он есть у ссылки; без cookie выбор детерминирован по отпечатку посетителя (IP и
+// User-Agent), поэтому повторный переход без cookie попадает в тот же вариант.
+// ID ссылки в хэше делает распределение независимым между тестами.
+func (l *Link) chooseVariant(visitor Visitor) (Variant, bool) {
+	if len(l.Variants) == 0 {
+		return Variant{}, false
+	}
+	total := 0
+	for _, variant := range l.Variants {
+		if variant.Name == visitor.Variant {
+			return variant, true
+		}
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return l.Variants[0], true
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(strconv.FormatInt(l.ID, 10) + ":" + visitor.Fingerprint))
+	point := int(hash.Sum64() % uint64(total))
+	for _, variant := range l.Variants {
+		if point < variant.Weight {
+			return variant, true
+		}
+		point -= variant.Weight
+	}
+	return l.Variants[len(l.Variants)-1], true
+}
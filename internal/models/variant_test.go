@@ -0,0 +1,41 @@
+package models
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLink_TargetVariants(t *testing.T) {
+	link := &Link{
+		ID:          42,
+		OriginalURL: "https://example.com/landing",
+		Variants: []Variant{
+			{Name: "control", URL: "https://example.com/landing", Weight: 3},
+			{Name: "new", URL: "https://example.com/landing-v2", Weight: 1},
+		},
+	}
+
+	// Без cookie выбор стабилен для одного посетителя
+	visitor := Visitor{Fingerprint: "10.0.0.1 Mozilla/5.0"}
+	first := link.Target(visitor)
+	assert.NotEmpty(t, first.Variant)
+	assert.Equal(t, first, link.Target(visitor))
+
+	// Вариант из cookie сохраняется, неизвестный вариант выбирается заново
+	assert.Equal(t, Target{URL: "https://example.com/landing-v2", Variant: "new"}, link.Target(Visitor{Variant: "new"}))
+	assert.NotEqual(t, "removed", link.Target(Visitor{Variant: "removed"}).Variant)
+
+	// Трафик делится пропорционально весам
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		counts[link.Target(Visitor{Fingerprint: "10.0.0." + strconv.Itoa(i)}).Variant]++
+	}
+	assert.InDelta(t, 3000, counts["control"], 200)
+	assert.InDelta(t, 1000, counts["new"], 200)
+
+	// Правила важнее вариантов
+	link.GeoRules = GeoRules{"DE": "https://example.de/landing"}
+	assert.Equal(t, Target{URL: "https://example.de/landing", Rule: "geo:DE"}, link.Target(Visitor{Country: "DE", Variant: "new"}))
+}
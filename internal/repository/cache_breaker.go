@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"go.uber.org/zap"
+)
+
+// ErrCacheUnavailable кэш временно отключён circuit breaker'ом
+var ErrCacheUnavailable = errors.New("cache unavailable")
+
+// maxPendingInvalidations ограничивает отложенные удаления. Ключи сверх лимита не
+// запоминаются: такие записи устаревают в Redis не дольше своего TTL.
+const maxPendingInvalidations = 10000
+
+// CircuitBreakerConfig конфигурация circuit breaker для кэша
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // Количество ошибок подряд для размыкания
+	CallTimeout      time.Duration // Таймаут одной операции с кэшем
+	ProbeInterval    time.Duration // Интервал фоновой проверки Redis в разомкнутом состоянии
+}
+
+// DefaultCircuitBreakerConfig конфигурация по умолчанию
+var DefaultCircuitBreakerConfig = CircuitBreakerConfig{
+	FailureThreshold: 5,
+	CallTimeout:      100 * time.Millisecond,
+	ProbeInterval:    5 * time.Second,
+}
+
+// CircuitBreakerCache оборачивает CacheRepository: при недоступном Redis операции
+// сразу возвращают ErrCacheUnavailable, а фоновая проверка восстанавливает кэш,
+// когда Redis снова отвечает. Так медленный Redis не добавляет задержку к каждому редиректу.
+// Ключи неудавшихся удалений и записей запоминаются и удаляются до того, как кэш снова
+// включится, иначе после восстановления Redis отдавал бы удалённые и изменённые ссылки
+// или negative-запись для уже созданной ссылки.
+type CircuitBreakerCache struct {
+	next   CacheRepository
+	ping   func(ctx context.Context) error
+	config CircuitBreakerConfig
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	open     bool
+	failures int
+	// pending ключи, удаление или запись которых не удалось выполнить
+	pending  map[string]struct{}
+	flushing atomic.Bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCircuitBreakerCache создаёт кэш с circuit breaker
+func NewCircuitBreakerCache(next CacheRepository, ping func(ctx context.Context) error, config CircuitBreakerConfig, logger *zap.Logger) *CircuitBreakerCache {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultCircuitBreakerConfig.FailureThreshold
+	}
+	if config.CallTimeout <= 0 {
+		config.CallTimeout = DefaultCircuitBreakerConfig.CallTimeout
+	}
+	if config.ProbeInterval <= 0 {
+		config.ProbeInterval = DefaultCircuitBreakerConfig.ProbeInterval
+	}
+
+	return &CircuitBreakerCache{
+		next:    next,
+		ping:    ping,
+		config:  config,
+		logger:  logger,
+		pending: make(map[string]struct{}),
+		stop:    make(chan struct{}),
+	}
+}
+
+func (b *CircuitBreakerCache) Get(ctx context.Context, key string) (*models.Link, error) {
+	var link *models.Link
+	err := b.call(ctx, func(ctx context.Context) error {
+		var err error
+		link, err = b.next.Get(ctx, key)
+		return err
+	})
+	return link, err
+}
+
+// Set записывает ссылку в кэш. Если запись не удалась, в Redis могла остаться прежняя
+// запись (например, negative-запись для только что созданной ссылки): ключ удаляется позже, как в Delete.
+func (b *CircuitBreakerCache) Set(ctx context.Context, key string, link *models.Link, ttl time.Duration) error {
+	return b.write(key, b.call(ctx, func(ctx context.Context) error {
+		return b.next.Set(ctx, key, link, ttl)
+	}))
+}
+
+// SetNotFound запоминает отсутствие ссылки. Неудавшаяся запись по таймауту могла
+// всё же выполниться, поэтому ключ удаляется позже, как в Delete.
+func (b *CircuitBreakerCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	return b.write(key, b.call(ctx, func(ctx context.Context) error {
+		return b.next.SetNotFound(ctx, key, ttl)
+	}))
+}
+
+// Delete удаляет ссылку из кэша. Если Redis недоступен, ключ запоминается и удаляется
+// позже: фоновой проверкой перед включением кэша или после следующей успешной операции записи.
+func (b *CircuitBreakerCache) Delete(ctx context.Context, key string) error {
+	return b.write(key, b.call(ctx, func(ctx context.Context) error {
+		return b.next.Delete(ctx, key)
+	}))
+}
+
+// write учитывает результат изменения ключа: при ошибке ключ откладывается на удаление,
+// при успехе запускается удаление ранее отложенных ключей
+func (b *CircuitBreakerCache) write(key string, err error) error {
+	if err != nil {
+		b.deferInvalidation(key)
+		return err
+	}
+
+	// Redis отвечает, а часть удалений ещё отложена (ошибки, не разомкнувшие breaker)
+	if b.hasPending() && b.flushing.CompareAndSwap(false, true) {
+		go func() {
+			defer b.flushing.Store(false)
+			if err := b.flushPending(context.Background()); err != nil {
+				b.logger.Warn("Не удалось выполнить отложенные удаления из кэша", zap.Error(err))
+			}
+		}()
+	}
+	return nil
+}
+
+// Trip принудительно размыкает breaker (например, если Redis недоступен при старте)
+func (b *CircuitBreakerCache) Trip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tripLocked()
+}
+
+// IsOpen сообщает, отключён ли кэш
+func (b *CircuitBreakerCache) IsOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
+
+// Close останавливает фоновую проверку
+func (b *CircuitBreakerCache) Close() {
+	b.stopOnce.Do(func() { close(b.stop) })
+}
+
+// deferInvalidation запоминает ключ, удаление или запись которого не удались
+func (b *CircuitBreakerCache) deferInvalidation(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.pending) >= maxPendingInvalidations {
+		b.logger.Warn("Слишком много отложенных удалений из кэша, ключ пропущен", zap.String("key", key))
+		return
+	}
+	b.pending[key] = struct{}{}
+	metrics.CacheInvalidationsPending.Set(float64(len(b.pending)))
+}
+
+func (b *CircuitBreakerCache) hasPending() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending) > 0
+}
+
+// flushPending выполняет отложенные удаления напрямую, минуя breaker. Ключи, которые
+// снова не удалось удалить, остаются отложенными.
+func (b *CircuitBreakerCache) flushPending(ctx context.Context) error {
+	b.mu.Lock()
+	keys := b.pending
+	b.pending = make(map[string]struct{})
+	b.mu.Unlock()
+
+	var failed []string
+	var lastErr error
+	for key := range keys {
+		callCtx, cancel := context.WithTimeout(ctx, b.config.CallTimeout)
+		err := b.next.Delete(callCtx, key)
+		cancel()
+		if err != nil {
+			failed = append(failed, key)
+			lastErr = err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range failed {
+		b.pending[key] = struct{}{}
+	}
+	metrics.CacheInvalidationsPending.Set(float64(len(b.pending)))
+
+	if lastErr != nil {
+		return fmt.Errorf("%d cache deletions failed: %w", len(failed), lastErr)
+	}
+	return nil
+}
+
+// call выполняет операцию с таймаутом и учитывает результат
+func (b *CircuitBreakerCache) call(ctx context.Context, op func(ctx context.Context) error) error {
+	if b.IsOpen() {
+		metrics.CacheCircuitRejections.Inc()
+		return ErrCacheUnavailable
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, b.config.CallTimeout)
+	defer cancel()
+
+	err := op(callCtx)
+
+	// Промах кэша, negative-запись и отмена запроса клиентом — не признак проблем с Redis
+	if err == nil || errors.Is(err, ErrCacheMiss) || errors.Is(err, ErrLinkNotFound) || ctx.Err() != nil {
+		b.recordSuccess()
+		return err
+	}
+
+	b.recordFailure(err)
+	return err
+}
+
+func (b *CircuitBreakerCache) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+}
+
+func (b *CircuitBreakerCache) recordFailure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if !b.open && b.failures >= b.config.FailureThreshold {
+		b.logger.Warn("Кэш отключён: Redis не отвечает",
+			zap.Int("failures", b.failures),
+			zap.Error(err),
+		)
+		b.tripLocked()
+	}
+}
+
+// tripLocked размыкает breaker и запускает фоновую проверку. Вызывается под mu.
+func (b *CircuitBreakerCache) tripLocked() {
+	if b.open {
+		return
+	}
+	b.open = true
+	metrics.CacheCircuitOpen.Set(1)
+	go b.probeLoop()
+}
+
+// probeLoop пингует Redis, пока он не ответит, после чего замыкает breaker
+func (b *CircuitBreakerCache) probeLoop() {
+	ticker := time.NewTicker(b.config.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), b.config.ProbeInterval)
+			err := b.ping(ctx)
+			cancel()
+			if err != nil {
+				b.logger.Debug("Redis всё ещё недоступен", zap.Error(err))
+				continue
+			}
+
+			// Пока breaker разомкнут, кэш не читается и не пишется: отложенные удаления
+			// выполняются до того, как кэш начнёт отдавать записи
+			if err := b.flushPending(context.Background()); err != nil {
+				b.logger.Debug("Отложенные удаления из кэша не выполнены", zap.Error(err))
+				continue
+			}
+
+			b.mu.Lock()
+			// Удаления, отложенные во время flushPending, выполнятся на следующей проверке
+			if len(b.pending) > 0 {
+				b.mu.Unlock()
+				continue
+			}
+			b.open = false
+			b.failures = 0
+			b.mu.Unlock()
+			metrics.CacheCircuitOpen.Set(0)
+
+			b.logger.Info("Redis снова доступен, кэш включён")
+			return
+		}
+	}
+}
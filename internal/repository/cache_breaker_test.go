@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// flakyCache кэш, который возвращает заданную ошибку, считает обращения и запоминает удалённые ключи
+type flakyCache struct {
+	err   atomic.Pointer[error]
+	calls atomic.Int32
+
+	mu      sync.Mutex
+	deleted []string
+}
+
+func (f *flakyCache) result() error {
+	f.calls.Add(1)
+	if err := f.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// setErr задаёт ошибку операций, nil — операции успешны
+func (f *flakyCache) setErr(err error) {
+	f.err.Store(&err)
+}
+
+func (f *flakyCache) Get(ctx context.Context, key string) (*models.Link, error) {
+	if err := f.result(); err != nil {
+		return nil, err
+	}
+	return &models.Link{ShortCode: key}, nil
+}
+
+func (f *flakyCache) Set(ctx context.Context, key string, link *models.Link, ttl time.Duration) error {
+	return f.result()
+}
+
+func (f *flakyCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	return f.result()
+}
+
+func (f *flakyCache) Delete(ctx context.Context, key string) error {
+	if err := f.result(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, key)
+	return nil
+}
+
+func (f *flakyCache) deletedKeys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.deleted)
+}
+
+// TestCircuitBreakerCache_TripsAndRecovers проверяет размыкание после ошибок и восстановление по фоновой проверке
+func TestCircuitBreakerCache_TripsAndRecovers(t *testing.T) {
+	next := &flakyCache{}
+	next.setErr(errors.New("i/o timeout"))
+
+	var redisUp atomic.Bool
+	ping := func(ctx context.Context) error {
+		if redisUp.Load() {
+			return nil
+		}
+		return errors.New("connection refused")
+	}
+
+	breaker := NewCircuitBreakerCache(next, ping, CircuitBreakerConfig{
+		FailureThreshold: 3,
+		CallTimeout:      50 * time.Millisecond,
+		ProbeInterval:    10 * time.Millisecond,
+	}, zap.NewNop())
+	defer breaker.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := breaker.Get(ctx, "code")
+		assert.Error(t, err)
+	}
+	assert.True(t, breaker.IsOpen())
+
+	// В разомкнутом состоянии Redis не вызывается
+	calls := next.calls.Load()
+	_, err := breaker.Get(ctx, "code")
+	assert.ErrorIs(t, err, ErrCacheUnavailable)
+	assert.Equal(t, calls, next.calls.Load())
+
+	// Redis восстановился — фоновая проверка замыкает breaker
+	next.setErr(ErrCacheMiss)
+	redisUp.Store(true)
+	assert.Eventually(t, func() bool { return !breaker.IsOpen() }, time.Second, 5*time.Millisecond)
+
+	_, err = breaker.Get(ctx, "code")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+}
+
+// TestCircuitBreakerCache_MissIsNotFailure проверяет, что промахи кэша не размыкают breaker
+func TestCircuitBreakerCache_MissIsNotFailure(t *testing.T) {
+	next := &flakyCache{}
+	next.setErr(ErrCacheMiss)
+
+	breaker := NewCircuitBreakerCache(next, func(ctx context.Context) error { return nil },
+		CircuitBreakerConfig{FailureThreshold: 2}, zap.NewNop())
+	defer breaker.Close()
+
+	for i := 0; i < 10; i++ {
+		_, err := breaker.Get(context.Background(), "code")
+		assert.ErrorIs(t, err, ErrCacheMiss)
+	}
+	assert.False(t, breaker.IsOpen())
+}
+
+// TestCircuitBreakerCache_CallTimeout проверяет, что медленный кэш ограничен таймаутом
+func TestCircuitBreakerCache_CallTimeout(t *testing.T) {
+	slow := &slowCache{}
+	breaker := NewCircuitBreakerCache(slow, func(ctx context.Context) error { return nil },
+		CircuitBreakerConfig{FailureThreshold: 1, CallTimeout: 20 * time.Millisecond, ProbeInterval: time.Hour}, zap.NewNop())
+	defer breaker.Close()
+
+	start := time.Now()
+	_, err := breaker.Get(context.Background(), "code")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.True(t, breaker.IsOpen())
+}
+
+// slowCache кэш, который отвечает только по истечении контекста
+type slowCache struct{ flakyCache }
+
+func (s *slowCache) Get(ctx context.Context, key string) (*models.Link, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// TestCircuitBreakerCache_DefersDeletes проверяет, что удаления при недоступном Redis
+// выполняются до включения кэша
+func TestCircuitBreakerCache_DefersDeletes(t *testing.T) {
+	next := &flakyCache{}
+	next.setErr(errors.New("i/o timeout"))
+
+	var redisUp atomic.Bool
+	ping := func(ctx context.Context) error {
+		if redisUp.Load() {
+			return nil
+		}
+		return errors.New("connection refused")
+	}
+
+	breaker := NewCircuitBreakerCache(next, ping, CircuitBreakerConfig{
+		FailureThreshold: 1,
+		ProbeInterval:    10 * time.Millisecond,
+	}, zap.NewNop())
+	defer breaker.Close()
+
+	ctx := context.Background()
+	_, err := breaker.Get(ctx, "code")
+	assert.Error(t, err)
+	assert.True(t, breaker.IsOpen())
+
+	assert.ErrorIs(t, breaker.Delete(ctx, "deleted"), ErrCacheUnavailable)
+
+	next.setErr(nil)
+	redisUp.Store(true)
+	assert.Eventually(t, func() bool { return !breaker.IsOpen() }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"deleted"}, next.deletedKeys())
+}
+
+// TestCircuitBreakerCache_RetriesFailedDelete проверяет повтор удаления, ошибка которого
+// не разомкнула breaker
+func TestCircuitBreakerCache_RetriesFailedDelete(t *testing.T) {
+	next := &flakyCache{}
+	breaker := NewCircuitBreakerCache(next, func(ctx context.Context) error { return nil },
+		CircuitBreakerConfig{FailureThreshold: 5}, zap.NewNop())
+	defer breaker.Close()
+
+	ctx := context.Background()
+	next.setErr(errors.New("i/o timeout"))
+	assert.Error(t, breaker.Delete(ctx, "first"))
+	assert.False(t, breaker.IsOpen())
+
+	next.setErr(nil)
+	assert.NoError(t, breaker.Delete(ctx, "second"))
+	assert.Eventually(t, func() bool {
+		return slices.Contains(next.deletedKeys(), "first")
+	}, time.Second, 5*time.Millisecond)
+}
+
+// TestCircuitBreakerCache_DefersFailedWrites проверяет, что ключи неудавшихся записей
+// удаляются до включения кэша: иначе negative-запись пережила бы создание ссылки
+func TestCircuitBreakerCache_DefersFailedWrites(t *testing.T) {
+	next := &flakyCache{}
+	next.setErr(errors.New("i/o timeout"))
+
+	var redisUp atomic.Bool
+	ping := func(ctx context.Context) error {
+		if redisUp.Load() {
+			return nil
+		}
+		return errors.New("connection refused")
+	}
+
+	breaker := NewCircuitBreakerCache(next, ping, CircuitBreakerConfig{
+		FailureThreshold: 1,
+		ProbeInterval:    10 * time.Millisecond,
+	}, zap.NewNop())
+	defer breaker.Close()
+
+	ctx := context.Background()
+	assert.Error(t, breaker.SetNotFound(ctx, "missing", time.Minute))
+	assert.True(t, breaker.IsOpen())
+	assert.ErrorIs(t, breaker.Set(ctx, "created", &models.Link{ShortCode: "created"}, time.Minute), ErrCacheUnavailable)
+
+	next.setErr(nil)
+	redisUp.Store(true)
+	assert.Eventually(t, func() bool { return !breaker.IsOpen() }, time.Second, 5*time.Millisecond)
+	assert.ElementsMatch(t, []string{"missing", "created"}, next.deletedKeys())
+}
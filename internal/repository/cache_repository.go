@@ -2,16 +2,29 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/redis/go-redis/v9"
 )
 
+// ErrCacheMiss ссылки нет в кэше
+var ErrCacheMiss = errors.New("cache miss")
+
+// notFoundMarker значение negative-записи: код точно отсутствует в БД.
+// Закодированная ссылка всегда непустая, поэтому пустое значение однозначно.
+const notFoundMarker = ""
+
+// CacheRepository кэш ссылок. Get возвращает ErrCacheMiss, если записи нет,
+// и ErrLinkNotFound, если закэшировано отсутствие ссылки (negative-запись).
 type CacheRepository interface {
 	Get(ctx context.Context, key string) (*models.Link, error)
 	Set(ctx context.Context, key string, link *models.Link, ttl time.Duration) error
+	// SetNotFound запоминает, что ссылки нет. Существующая запись не перезаписывается,
+	// поэтому negative-запись не может затереть ссылку, созданную параллельно.
+	SetNotFound(ctx context.Context, key string, ttl time.Duration) error
 	Delete(ctx context.Context, key string) error
 }
 
@@ -26,24 +39,30 @@ func NewCacheRepository(redis *RedisDB) CacheRepository {
 func (r *cacheRepository) Get(ctx context.Context, key string) (*models.Link, error) {
 	data, err := r.redis.Client.Get(ctx, r.key(key)).Bytes()
 	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrCacheMiss
+		}
 		return nil, err
 	}
+	if string(data) == notFoundMarker {
+		return nil, ErrLinkNotFound
+	}
 
-	var link models.Link
-	if err := json.Unmarshal(data, &link); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal link: %w", err)
+	// Повреждённая запись считается промахом: ссылка перечитается из БД и перезапишет её
+	link, err := decodeLink(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCacheMiss, err)
 	}
 
-	return &link, nil
+	return link, nil
 }
 
 func (r *cacheRepository) Set(ctx context.Context, key string, link *models.Link, ttl time.Duration) error {
-	data, err := json.Marshal(link)
-	if err != nil {
-		return fmt.Errorf("failed to marshal link: %w", err)
-	}
+	return r.redis.Client.Set(ctx, r.key(key), encodeLink(link), ttl).Err()
+}
 
-	return r.redis.Client.Set(ctx, r.key(key), data, ttl).Err()
+func (r *cacheRepository) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	return r.redis.Client.SetNX(ctx, r.key(key), notFoundMarker, ttl).Err()
 }
 
 func (r *cacheRepository) Delete(ctx context.Context, key string) error {
@@ -51,5 +70,10 @@ func (r *cacheRepository) Delete(ctx context.Context, key string) error {
 }
 
 func (r *cacheRepository) key(key string) string {
-	return "link:" + key
+	return LinkCacheKey(key)
+}
+
+// LinkCacheKey ключ Redis для ключа ссылки models.LinkKey с префиксом текущей версии формата
+func LinkCacheKey(key string) string {
+	return linkKeyPrefix + key
 }
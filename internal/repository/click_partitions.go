@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// partitionLockKey ключ advisory lock: DDL партиций от нескольких инстансов выполняется по очереди
+const partitionLockKey int64 = 0x75726c2d706172 // "url-par"
+
+// defaultPartition партиция по умолчанию: в неё попадают клики месяцев, для которых
+// партиция ещё не создана
+const defaultPartition = "clicks_default"
+
+// ClickArchiveSchema схема, в которую переносятся старые партиции вместо удаления
+const ClickArchiveSchema = "clicks_archive"
+
+// partitionNamePattern имя помесячной партиции: clicks_p2026_10
+var partitionNamePattern = regexp.MustCompile(`^clicks_p(\d{4})_(\d{2})$`)
+
+// ClickPartitionRepository управление помесячными партициями таблицы clicks
+type ClickPartitionRepository interface {
+	// Partitioned сообщает, что таблица clicks партиционирована (миграция 000002 применена)
+	Partitioned(ctx context.Context) (bool, error)
+	// EnsurePartitions создаёт партиции на месяцы [from, from+months] и возвращает имена созданных.
+	// Каждый месяц создаётся отдельной транзакцией: ошибка одного месяца не мешает остальным
+	// и возвращается вместе с именами созданных партиций.
+	EnsurePartitions(ctx context.Context, from time.Time, months int) ([]string, error)
+	// ListPartitions возвращает помесячные партиции по возрастанию месяца (без партиции по умолчанию)
+	ListPartitions(ctx context.Context) ([]models.ClickPartition, error)
+	// RemovePartition отсоединяет партицию и удаляет её или переносит в ClickArchiveSchema.
+	// Возвращает false, если партиция уже отсоединена другим инстансом.
+	RemovePartition(ctx context.Context, name string, archive bool) (bool, error)
+}
+
+type clickPartitionRepository struct {
+	db *PostgresDB
+}
+
+func NewClickPartitionRepository(db *PostgresDB) ClickPartitionRepository {
+	return &clickPartitionRepository{db: db}
+}
+
+func (r *clickPartitionRepository) Partitioned(ctx context.Context) (bool, error) {
+	// relkind 'p' — партиционированная таблица, 'r' — обычная
+	var partitioned bool
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT COALESCE((SELECT relkind = 'p' FROM pg_class WHERE oid = to_regclass('clicks')), false)`,
+	).Scan(&partitioned)
+	if err != nil {
+		return false, fmt.Errorf("failed to check clicks partitioning: %w", err)
+	}
+	return partitioned, nil
+}
+
+func (r *clickPartitionRepository) EnsurePartitions(ctx context.Context, from time.Time, months int) ([]string, error) {
+	var (
+		created []string
+		errs    []error
+	)
+
+	start := monthStart(from)
+	for i := 0; i <= months; i++ {
+		month := start.AddDate(0, i, 0)
+		name := partitionName(month)
+
+		ok, err := r.ensurePartition(ctx, name, month)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create click partition %s: %w", name, err))
+			continue
+		}
+		if ok {
+			created = append(created, name)
+		}
+	}
+
+	return created, errors.Join(errs...)
+}
+
+// ensurePartition создаёт партицию месяца, если её нет. Клики этого месяца, уже попавшие
+// в партицию по умолчанию (задача отстала или сервис не работал), переносятся в новую
+// партицию: иначе PostgreSQL отказывается её создавать.
+func (r *clickPartitionRepository) ensurePartition(ctx context.Context, name string, month time.Time) (bool, error) {
+	var created bool
+
+	err := r.withLock(ctx, func(tx pgx.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+
+		from, to := month.Format(time.DateOnly), month.AddDate(0, 1, 0).Format(time.DateOnly)
+		table := pgx.Identifier{name}.Sanitize()
+		defaultTable := pgx.Identifier{defaultPartition}.Sanitize()
+
+		var hasDefault, pending bool
+		if err := tx.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, defaultPartition).Scan(&hasDefault); err != nil {
+			return err
+		}
+		if hasDefault {
+			err := tx.QueryRow(ctx,
+				`SELECT EXISTS (SELECT 1 FROM `+defaultTable+` WHERE clicked_at >= $1 AND clicked_at < $2)`,
+				from, to,
+			).Scan(&pending)
+			if err != nil {
+				return err
+			}
+		}
+
+		// DETACH берёт исключительную блокировку clicks: вставки кликов ждут конца транзакции
+		if pending {
+			if _, err := tx.Exec(ctx, `ALTER TABLE clicks DETACH PARTITION `+defaultTable); err != nil {
+				return err
+			}
+		}
+
+		// Границы — литералы: параметры в DDL не поддерживаются, значения формируются здесь же
+		query := fmt.Sprintf(`CREATE TABLE %s PARTITION OF clicks FOR VALUES FROM ('%s') TO ('%s')`, table, from, to)
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return err
+		}
+
+		if pending {
+			// Обе таблицы — партиции clicks, порядок колонок у них совпадает
+			_, err := tx.Exec(ctx, `
+				WITH moved AS (
+					DELETE FROM `+defaultTable+` WHERE clicked_at >= $1 AND clicked_at < $2
+					RETURNING *
+				)
+				INSERT INTO `+table+` SELECT * FROM moved
+			`, from, to)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `ALTER TABLE clicks ATTACH PARTITION `+defaultTable+` DEFAULT`); err != nil {
+				return err
+			}
+		}
+
+		created = true
+		return nil
+	})
+
+	return created, err
+}
+
+func (r *clickPartitionRepository) ListPartitions(ctx context.Context) ([]models.ClickPartition, error) {
+	query := `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'clicks'::regclass
+		ORDER BY c.relname
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list click partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []models.ClickPartition
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan click partition: %w", err)
+		}
+		month, ok := parsePartitionName(name)
+		if !ok {
+			continue
+		}
+		partitions = append(partitions, models.ClickPartition{Name: name, Month: month})
+	}
+
+	return partitions, rows.Err()
+}
+
+func (r *clickPartitionRepository) RemovePartition(ctx context.Context, name string, archive bool) (bool, error) {
+	var removed bool
+
+	err := r.withLock(ctx, func(tx pgx.Tx) error {
+		var attached bool
+		err := tx.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM pg_inherits
+				WHERE inhparent = 'clicks'::regclass AND inhrelid = to_regclass($1)
+			)
+		`, name).Scan(&attached)
+		if err != nil || !attached {
+			return err
+		}
+
+		table := pgx.Identifier{name}.Sanitize()
+		if _, err := tx.Exec(ctx, `ALTER TABLE clicks DETACH PARTITION `+table); err != nil {
+			return err
+		}
+
+		if archive {
+			schema := pgx.Identifier{ClickArchiveSchema}.Sanitize()
+			if _, err := tx.Exec(ctx, `CREATE SCHEMA IF NOT EXISTS `+schema); err != nil {
+				return err
+			}
+			_, err = tx.Exec(ctx, `ALTER TABLE `+table+` SET SCHEMA `+schema)
+		} else {
+			_, err = tx.Exec(ctx, `DROP TABLE `+table)
+		}
+		removed = err == nil
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to remove click partition %s: %w", name, err)
+	}
+
+	return removed, nil
+}
+
+// withLock выполняет fn в транзакции под advisory lock, который снимается вместе с ней
+func (r *clickPartitionRepository) withLock(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, r.db.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, partitionLockKey); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// monthStart начало месяца по настенному времени t: clicked_at хранится без часового пояса
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func partitionName(month time.Time) string {
+	return fmt.Sprintf("clicks_p%04d_%02d", month.Year(), int(month.Month()))
+}
+
+func parsePartitionName(name string) (time.Time, bool) {
+	match := partitionNamePattern.FindStringSubmatch(name)
+	if match == nil {
+		return time.Time{}, false
+	}
+	year, _ := strconv.Atoi(match[1])
+	month, _ := strconv.Atoi(match[2])
+	if month < 1 || month > 12 {
+		return time.Time{}, false
+	}
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC), true
+}
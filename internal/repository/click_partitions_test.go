@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPartitionName проверяет имя партиции и его разбор
+func TestPartitionName(t *testing.T) {
+	month := monthStart(time.Date(2026, time.October, 18, 23, 30, 0, 0, time.FixedZone("MSK", 3*3600)))
+	assert.Equal(t, time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC), month)
+	assert.Equal(t, "clicks_p2026_10", partitionName(month))
+
+	parsed, ok := parsePartitionName("clicks_p2026_10")
+	assert.True(t, ok)
+	assert.Equal(t, month, parsed)
+
+	for _, name := range []string{"clicks_default", "clicks_p2026_13", "clicks_p2026_1"} {
+		_, ok := parsePartitionName(name)
+		assert.False(t, ok, name)
+	}
+}
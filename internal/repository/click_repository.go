@@ -7,13 +7,14 @@ import (
 
 	"github.com/SergeiKhy/url-shortener/internal/models"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type ClickRepository interface {
 	RecordClick(ctx context.Context, click *models.Click) error
-	GetStats(ctx context.Context, shortCode string) (*models.ClickStats, error)
-	GetDailyStats(ctx context.Context, shortCode string, days int) ([]models.DailyClickStats, error)
-	GetLinkIDByShortCode(ctx context.Context, shortCode string) (int64, error)
+	GetStats(ctx context.Context, domain, shortCode string) (*models.ClickStats, error)
+	GetDailyStats(ctx context.Context, domain, shortCode string, days int) ([]models.DailyClickStats, error)
+	GetLinkIDByShortCode(ctx context.Context, domain, shortCode string) (int64, error)
 }
 
 type clickRepository struct {
@@ -26,8 +27,8 @@ func NewClickRepository(db *PostgresDB) ClickRepository {
 
 func (r *clickRepository) RecordClick(ctx context.Context, click *models.Click) error {
 	query := `
-		INSERT INTO clicks (link_id, ip_address, user_agent, referer, country, clicked_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO clicks (link_id, ip_address, user_agent, referer, country, rule, variant, clicked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
 	_, err := r.db.Pool.Exec(ctx, query,
@@ -36,6 +37,8 @@ func (r *clickRepository) RecordClick(ctx context.Context, click *models.Click)
 		click.UserAgent,
 		click.Referer,
 		click.Country,
+		click.Rule,
+		click.Variant,
 		click.ClickedAt,
 	)
 
@@ -46,24 +49,52 @@ func (r *clickRepository) RecordClick(ctx context.Context, click *models.Click)
 	return nil
 }
 
-func (r *clickRepository) GetStats(ctx context.Context, shortCode string) (*models.ClickStats, error) {
+func (r *clickRepository) GetStats(ctx context.Context, domain, shortCode string) (*models.ClickStats, error) {
 	query := `
 		SELECT 
 			COUNT(*) as total_clicks,
 			COUNT(DISTINCT ip_address) as unique_clicks
 		FROM clicks c
 		JOIN links l ON c.link_id = l.id
-		WHERE l.short_code = $1
+		WHERE l.domain = $1 AND l.short_code = $2
+	`
+
+	variantsQuery := `
+		SELECT
+			c.variant,
+			COUNT(*) as total_clicks,
+			COUNT(DISTINCT ip_address) as unique_clicks
+		FROM clicks c
+		JOIN links l ON c.link_id = l.id
+		WHERE l.domain = $1 AND l.short_code = $2 AND c.variant <> ''
+		GROUP BY c.variant
+		ORDER BY c.variant
 	`
 
 	stats := &models.ClickStats{
 		ShortCode: shortCode,
 	}
 
-	err := r.db.Pool.QueryRow(ctx, query, shortCode).Scan(
-		&stats.TotalClicks,
-		&stats.UniqueClicks,
-	)
+	err := r.db.read(ctx, func(pool *pgxpool.Pool) error {
+		err := pool.QueryRow(ctx, query, domain, shortCode).Scan(
+			&stats.TotalClicks,
+			&stats.UniqueClicks,
+		)
+		if err != nil {
+			return err
+		}
+
+		rows, err := pool.Query(ctx, variantsQuery, domain, shortCode)
+		if err != nil {
+			return err
+		}
+		stats.Variants, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.VariantClickStats, error) {
+			var variant models.VariantClickStats
+			err := row.Scan(&variant.Variant, &variant.TotalClicks, &variant.UniqueClicks)
+			return variant, err
+		})
+		return err
+	}, nil)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get click stats: %w", err)
@@ -72,49 +103,65 @@ func (r *clickRepository) GetStats(ctx context.Context, shortCode string) (*mode
 	return stats, nil
 }
 
-func (r *clickRepository) GetDailyStats(ctx context.Context, shortCode string, days int) ([]models.DailyClickStats, error) {
+func (r *clickRepository) GetDailyStats(ctx context.Context, domain, shortCode string, days int) ([]models.DailyClickStats, error) {
 	query := `
 		SELECT 
 			DATE(c.clicked_at) as date,
+			c.variant,
 			COUNT(*) as clicks
 		FROM clicks c
 		JOIN links l ON c.link_id = l.id
-		WHERE l.short_code = $1 
-			AND c.clicked_at >= NOW() - INTERVAL '1 day' * $2
-		GROUP BY DATE(c.clicked_at)
-		ORDER BY date DESC
+		WHERE l.domain = $1 AND l.short_code = $2
+			AND c.clicked_at >= NOW() - INTERVAL '1 day' * $3
+		GROUP BY DATE(c.clicked_at), c.variant
+		ORDER BY date DESC, c.variant
 	`
 
-	rows, err := r.db.Pool.Query(ctx, query, shortCode, days)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return []models.DailyClickStats{}, nil
-		}
-		return nil, fmt.Errorf("failed to get daily stats: %w", err)
-	}
-	defer rows.Close()
-
 	var stats []models.DailyClickStats
-	for rows.Next() {
-		var dailyStat models.DailyClickStats
-		if err := rows.Scan(&dailyStat.Date, &dailyStat.Clicks); err != nil {
-			return nil, fmt.Errorf("failed to scan daily stat: %w", err)
+	err := r.db.read(ctx, func(pool *pgxpool.Pool) error {
+		stats = nil
+		rows, err := pool.Query(ctx, query, domain, shortCode, days)
+		if err != nil {
+			return err
 		}
-		stats = append(stats, dailyStat)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating daily stats: %w", err)
+		defer rows.Close()
+
+		// Строки дня идут подряд, по строке на вариант; клики без варианта входят только в сумму дня
+		for rows.Next() {
+			var (
+				date    string
+				variant string
+				clicks  int64
+			)
+			if err := rows.Scan(&date, &variant, &clicks); err != nil {
+				return fmt.Errorf("failed to scan daily stat: %w", err)
+			}
+			if len(stats) == 0 || stats[len(stats)-1].Date != date {
+				stats = append(stats, models.DailyClickStats{Date: date})
+			}
+			dailyStat := &stats[len(stats)-1]
+			dailyStat.Clicks += clicks
+			if variant != "" {
+				if dailyStat.Variants == nil {
+					dailyStat.Variants = make(map[string]int64)
+				}
+				dailyStat.Variants[variant] = clicks
+			}
+		}
+		return rows.Err()
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily stats: %w", err)
 	}
 
 	return stats, nil
 }
 
-func (r *clickRepository) GetLinkIDByShortCode(ctx context.Context, shortCode string) (int64, error) {
-	query := `SELECT id FROM links WHERE short_code = $1`
+func (r *clickRepository) GetLinkIDByShortCode(ctx context.Context, domain, shortCode string) (int64, error) {
+	query := `SELECT id FROM links WHERE domain = $1 AND short_code = $2`
 
 	var linkID int64
-	err := r.db.Pool.QueryRow(ctx, query, shortCode).Scan(&linkID)
+	err := r.db.Pool.QueryRow(ctx, query, domain, shortCode).Scan(&linkID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return 0, ErrLinkNotFound
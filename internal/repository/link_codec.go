@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/models"
+)
+
+// linkKeyPrefix версионированный префикс ключей кэша. При смене версии старые и новые
+// инстансы при выкатке читают и пишут разные ключи, а записи прежней версии истекают по TTL.
+// v3: хэш пароля, лимит переходов, время активации, fallback URL, правила и варианты.
+const linkKeyPrefix = "link:v3:"
+
+// Теги полей закэшированной ссылки (в скобках — почему поле требует версии префикса).
+// Каждое поле кодируется как
+// тег (1 байт), длина значения (uvarint) и значение. Неизвестные теги пропускаются,
+// а отсутствующие дают нулевое значение поля. Поэтому без смены версии префикса можно
+// добавить только поле, без которого старая запись по-прежнему верна (например, заголовок
+// для страницы). Поле, влияющее на доступ к ссылке (пароль, лимит, время действия) или на
+// выбор адреса назначения, требует новой версии: иначе старый инстанс во время выкатки
+// запишет ссылку без него под тем же ключом, и новые инстансы обойдут проверку до истечения TTL.
+const (
+	fieldID           byte = 1  // uvarint
+	fieldOriginalURL  byte = 2  // строка
+	fieldExpiresAt    byte = 3  // varint, Unix время в миллисекундах
+	fieldRedirectType byte = 4  // uvarint, HTTP статус
+	fieldPasswordHash byte = 5  // строка, bcrypt хэш (доступ)
+	fieldMaxClicks    byte = 6  // uvarint (доступ)
+	fieldStartsAt     byte = 7  // varint, Unix время в миллисекундах (доступ)
+	fieldFallbackURL  byte = 8  // строка (адрес назначения)
+	fieldCreatedAt    byte = 9  // varint, Unix время в миллисекундах
+	fieldTitle        byte = 10 // строка
+	fieldPreview      byte = 11 // uvarint, 1 — обязательный предпросмотр
+	fieldGeoRule      byte = 12 // код страны (2 байта) и адрес назначения, по полю на правило (адрес назначения)
+	fieldDeviceRule   byte = 13 // вложенные поля deviceRule*, по полю на правило (адрес назначения)
+	fieldVariant      byte = 14 // вложенные поля variant*, по полю на вариант в порядке ссылки (адрес назначения)
+)
+
+// Вложенные поля правила по устройству в значении fieldDeviceRule
+const (
+	deviceRulePlatform byte = 1 // строка
+	deviceRuleURL      byte = 2 // строка
+	deviceRuleDeepLink byte = 3 // строка
+)
+
+// Вложенные поля варианта A/B теста в значении fieldVariant
+const (
+	variantName   byte = 1 // строка
+	variantURL    byte = 2 // строка
+	variantWeight byte = 3 // uvarint
+)
+
+var errCorruptedLink = errors.New("corrupted cached link")
+
+// encodeLink кодирует только поля, нужные для редиректа, включая хэш пароля для его проверки.
+// Домен и короткий код хранятся в ключе.
+func encodeLink(link *models.Link) []byte {
+	buf := make([]byte, 0, len(link.OriginalURL)+2*binary.MaxVarintLen64+8)
+
+	buf = appendUvarintField(buf, fieldID, uint64(link.ID))
+	buf = appendBytesField(buf, fieldOriginalURL, []byte(link.OriginalURL))
+	if link.ExpiresAt != nil {
+		buf = appendVarintField(buf, fieldExpiresAt, link.ExpiresAt.UnixMilli())
+	}
+	if link.RedirectType != 0 {
+		buf = appendUvarintField(buf, fieldRedirectType, uint64(link.RedirectType))
+	}
+	if link.PasswordHash != "" {
+		buf = appendBytesField(buf, fieldPasswordHash, []byte(link.PasswordHash))
+	}
+	if link.MaxClicks > 0 {
+		buf = appendUvarintField(buf, fieldMaxClicks, uint64(link.MaxClicks))
+	}
+	if link.StartsAt != nil {
+		buf = appendVarintField(buf, fieldStartsAt, link.StartsAt.UnixMilli())
+	}
+	if link.FallbackURL != "" {
+		buf = appendBytesField(buf, fieldFallbackURL, []byte(link.FallbackURL))
+	}
+	// Дата создания и заголовок нужны странице предпросмотра
+	if !link.CreatedAt.IsZero() {
+		buf = appendVarintField(buf, fieldCreatedAt, link.CreatedAt.UnixMilli())
+	}
+	if link.Title != "" {
+		buf = appendBytesField(buf, fieldTitle, []byte(link.Title))
+	}
+	if link.Preview {
+		buf = appendUvarintField(buf, fieldPreview, 1)
+	}
+	for _, country := range slices.Sorted(maps.Keys(link.GeoRules)) {
+		buf = appendBytesField(buf, fieldGeoRule, []byte(country+link.GeoRules[country]))
+	}
+	for _, platform := range slices.Sorted(maps.Keys(link.DeviceRules)) {
+		rule := link.DeviceRules[platform]
+		value := appendBytesField(nil, deviceRulePlatform, []byte(platform))
+		value = appendBytesField(value, deviceRuleURL, []byte(rule.URL))
+		if rule.DeepLink != "" {
+			value = appendBytesField(value, deviceRuleDeepLink, []byte(rule.DeepLink))
+		}
+		buf = appendBytesField(buf, fieldDeviceRule, value)
+	}
+	for _, variant := range link.Variants {
+		value := appendBytesField(nil, variantName, []byte(variant.Name))
+		value = appendBytesField(value, variantURL, []byte(variant.URL))
+		value = appendUvarintField(value, variantWeight, uint64(variant.Weight))
+		buf = appendBytesField(buf, fieldVariant, value)
+	}
+
+	return buf
+}
+
+// decodeLink восстанавливает ссылку из закэшированного значения по ключу models.LinkKey
+func decodeLink(key string, data []byte) (*models.Link, error) {
+	link := &models.Link{}
+	link.Domain, link.ShortCode = models.SplitLinkKey(key)
+
+	for len(data) > 0 {
+		tag, value, rest, err := nextField(data)
+		if err != nil {
+			return nil, err
+		}
+		data = rest
+
+		switch tag {
+		case fieldID:
+			id, err := uvarintValue(value)
+			if err != nil {
+				return nil, err
+			}
+			link.ID = int64(id)
+		case fieldOriginalURL:
+			link.OriginalURL = string(value)
+		case fieldExpiresAt:
+			ms, err := varintValue(value)
+			if err != nil {
+				return nil, err
+			}
+			expiresAt := time.UnixMilli(ms)
+			link.ExpiresAt = &expiresAt
+		case fieldRedirectType:
+			status, err := uvarintValue(value)
+			if err != nil {
+				return nil, err
+			}
+			link.RedirectType = int(status)
+		case fieldPasswordHash:
+			link.PasswordHash = string(value)
+		case fieldMaxClicks:
+			maxClicks, err := uvarintValue(value)
+			if err != nil {
+				return nil, err
+			}
+			link.MaxClicks = int(maxClicks)
+		case fieldStartsAt:
+			ms, err := varintValue(value)
+			if err != nil {
+				return nil, err
+			}
+			startsAt := time.UnixMilli(ms)
+			link.StartsAt = &startsAt
+		case fieldFallbackURL:
+			link.FallbackURL = string(value)
+		case fieldCreatedAt:
+			ms, err := varintValue(value)
+			if err != nil {
+				return nil, err
+			}
+			link.CreatedAt = time.UnixMilli(ms)
+		case fieldTitle:
+			link.Title = string(value)
+		case fieldPreview:
+			preview, err := uvarintValue(value)
+			if err != nil {
+				return nil, err
+			}
+			link.Preview = preview == 1
+		case fieldGeoRule:
+			if len(value) <= 2 {
+				return nil, fmt.Errorf("%w: invalid geo rule", errCorruptedLink)
+			}
+			if link.GeoRules == nil {
+				link.GeoRules = make(models.GeoRules)
+			}
+			link.GeoRules[string(value[:2])] = string(value[2:])
+		case fieldDeviceRule:
+			platform, rule, err := decodeDeviceRule(value)
+			if err != nil {
+				return nil, err
+			}
+			if link.DeviceRules == nil {
+				link.DeviceRules = make(models.DeviceRules)
+			}
+			link.DeviceRules[platform] = rule
+		case fieldVariant:
+			variant, err := decodeVariant(value)
+			if err != nil {
+				return nil, err
+			}
+			link.Variants = append(link.Variants, variant)
+		}
+	}
+
+	if link.OriginalURL == "" {
+		return nil, fmt.Errorf("%w: missing original URL", errCorruptedLink)
+	}
+	return link, nil
+}
+
+// decodeDeviceRule читает вложенные поля правила по устройству
+func decodeDeviceRule(data []byte) (platform string, rule models.DeviceRule, err error) {
+	for len(data) > 0 {
+		tag, value, rest, err := nextField(data)
+		if err != nil {
+			return "", models.DeviceRule{}, err
+		}
+		data = rest
+
+		switch tag {
+		case deviceRulePlatform:
+			platform = string(value)
+		case deviceRuleURL:
+			rule.URL = string(value)
+		case deviceRuleDeepLink:
+			rule.DeepLink = string(value)
+		}
+	}
+	if platform == "" || rule.URL == "" {
+		return "", models.DeviceRule{}, fmt.Errorf("%w: invalid device rule", errCorruptedLink)
+	}
+	return platform, rule, nil
+}
+
+// decodeVariant читает вложенные поля варианта A/B теста
+func decodeVariant(data []byte) (models.Variant, error) {
+	var variant models.Variant
+	for len(data) > 0 {
+		tag, value, rest, err := nextField(data)
+		if err != nil {
+			return models.Variant{}, err
+		}
+		data = rest
+
+		switch tag {
+		case variantName:
+			variant.Name = string(value)
+		case variantURL:
+			variant.URL = string(value)
+		case variantWeight:
+			weight, err := uvarintValue(value)
+			if err != nil {
+				return models.Variant{}, err
+			}
+			variant.Weight = int(weight)
+		}
+	}
+	if variant.Name == "" || variant.URL == "" {
+		return models.Variant{}, fmt.Errorf("%w: invalid variant", errCorruptedLink)
+	}
+	return variant, nil
+}
+
+// nextField читает первое поле из data и возвращает его тег, значение и оставшиеся данные
+func nextField(data []byte) (tag byte, value, rest []byte, err error) {
+	size, n := binary.Uvarint(data[1:])
+	if n <= 0 || uint64(len(data)-1-n) < size {
+		return 0, nil, nil, errCorruptedLink
+	}
+	return data[0], data[1+n : 1+n+int(size)], data[1+n+int(size):], nil
+}
+
+func appendBytesField(buf []byte, tag byte, value []byte) []byte {
+	buf = append(buf, tag)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func appendUvarintField(buf []byte, tag byte, value uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return appendBytesField(buf, tag, binary.AppendUvarint(tmp[:0], value))
+}
+
+func appendVarintField(buf []byte, tag byte, value int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return appendBytesField(buf, tag, binary.AppendVarint(tmp[:0], value))
+}
+
+func uvarintValue(value []byte) (uint64, error) {
+	v, n := binary.Uvarint(value)
+	if n <= 0 || n != len(value) {
+		return 0, errCorruptedLink
+	}
+	return v, nil
+}
+
+func varintValue(value []byte) (int64, error) {
+	v, n := binary.Varint(value)
+	if n <= 0 || n != len(value) {
+		return 0, errCorruptedLink
+	}
+	return v, nil
+}
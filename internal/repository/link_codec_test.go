@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLink() *models.Link {
+	expiresAt := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+	return &models.Link{
+		ID:           123456,
+		ShortCode:    "aB3dE5fG",
+		OriginalURL:  "https://example.com/some/fairly/long/path?utm_source=newsletter&utm_campaign=spring",
+		RedirectType: models.RedirectPermanent,
+		ExpiresAt:    &expiresAt,
+		CreatedAt:    time.Now(),
+	}
+}
+
+// TestLinkCodec_RoundTrip проверяет кодирование и декодирование полей редиректа
+func TestLinkCodec_RoundTrip(t *testing.T) {
+	link := testLink()
+
+	decoded, err := decodeLink(link.ShortCode, encodeLink(link))
+	require.NoError(t, err)
+	assert.Equal(t, link.ID, decoded.ID)
+	assert.Equal(t, link.ShortCode, decoded.ShortCode)
+	assert.Equal(t, link.OriginalURL, decoded.OriginalURL)
+	assert.Equal(t, link.RedirectType, decoded.RedirectType)
+	assert.Empty(t, decoded.PasswordHash)
+	assert.Zero(t, decoded.MaxClicks)
+	assert.Nil(t, decoded.StartsAt)
+	assert.Empty(t, decoded.FallbackURL)
+	assert.False(t, decoded.Preview)
+	assert.Nil(t, decoded.GeoRules)
+	assert.Nil(t, decoded.DeviceRules)
+	assert.Nil(t, decoded.Variants)
+	require.NotNil(t, decoded.ExpiresAt)
+	assert.True(t, link.ExpiresAt.Equal(*decoded.ExpiresAt))
+
+	// Ссылка без срока действия
+	link.ExpiresAt = nil
+	decoded, err = decodeLink(link.ShortCode, encodeLink(link))
+	require.NoError(t, err)
+	assert.Nil(t, decoded.ExpiresAt)
+
+	// Записи без типа редиректа (сохранённые до его появления) редиректят с 307
+	link.RedirectType = 0
+	decoded, err = decodeLink(link.ShortCode, encodeLink(link))
+	require.NoError(t, err)
+	assert.Equal(t, models.RedirectTemporary, decoded.RedirectStatus())
+
+	// Хэш пароля нужен редиректу для проверки без обращения к БД
+	link.PasswordHash = "$2a$10$abcdefghijklmnopqrstuuJ6mWy6e9Y9cXl5eS8rYy9vW1rU2Jg7C"
+	decoded, err = decodeLink(link.ShortCode, encodeLink(link))
+	require.NoError(t, err)
+	assert.Equal(t, link.PasswordHash, decoded.PasswordHash)
+
+	// Лимит переходов хранится, счётчик использованных — нет: он меняется при каждом переходе
+	link.MaxClicks, link.UsedClicks = 1, 1
+	decoded, err = decodeLink(link.ShortCode, encodeLink(link))
+	require.NoError(t, err)
+	assert.Equal(t, 1, decoded.MaxClicks)
+	assert.Zero(t, decoded.UsedClicks)
+
+	// Время активации проверяется и на пути через кэш
+	startsAt := time.UnixMilli(time.Now().Add(time.Minute).UnixMilli())
+	link.StartsAt = &startsAt
+	decoded, err = decodeLink(link.ShortCode, encodeLink(link))
+	require.NoError(t, err)
+	require.NotNil(t, decoded.StartsAt)
+	assert.True(t, startsAt.Equal(*decoded.StartsAt))
+	assert.True(t, decoded.NotStarted(time.Now()))
+
+	// Адрес после истечения нужен редиректу истёкшей ссылки
+	link.FallbackURL = "https://example.com/archive"
+	decoded, err = decodeLink(link.ShortCode, encodeLink(link))
+	require.NoError(t, err)
+	assert.Equal(t, link.FallbackURL, decoded.FallbackURL)
+
+	// Заголовок, дата создания и флаг предпросмотра нужны странице предпросмотра
+	link.Title, link.Preview = "Quarterly report", true
+	link.CreatedAt = time.UnixMilli(time.Now().UnixMilli())
+	decoded, err = decodeLink(link.ShortCode, encodeLink(link))
+	require.NoError(t, err)
+	assert.Equal(t, link.Title, decoded.Title)
+	assert.True(t, decoded.Preview)
+	assert.True(t, link.CreatedAt.Equal(decoded.CreatedAt))
+
+	// Правила геотаргетинга кэшируются вместе со ссылкой
+	link.GeoRules = models.GeoRules{"DE": "https://shop.example.de", "FR": "https://shop.example.fr"}
+	decoded, err = decodeLink(link.ShortCode, encodeLink(link))
+	require.NoError(t, err)
+	assert.Equal(t, link.GeoRules, decoded.GeoRules)
+
+	// Правила по устройству: deep link необязателен
+	link.DeviceRules = models.DeviceRules{
+		models.PlatformIOS:     {URL: "https://apps.apple.com/app/id123", DeepLink: "shop://home"},
+		models.PlatformAndroid: {URL: "https://play.google.com/store/apps/details?id=com.example.shop"},
+	}
+	decoded, err = decodeLink(link.ShortCode, encodeLink(link))
+	require.NoError(t, err)
+	assert.Equal(t, link.DeviceRules, decoded.DeviceRules)
+
+	// Варианты A/B теста сохраняют порядок
+	link.Variants = []models.Variant{
+		{Name: "control", URL: "https://example.com/landing", Weight: 3},
+		{Name: "new", URL: "https://example.com/landing-v2", Weight: 1},
+	}
+	decoded, err = decodeLink(link.ShortCode, encodeLink(link))
+	require.NoError(t, err)
+	assert.Equal(t, link.Variants, decoded.Variants)
+
+	// Домен восстанавливается из ключа
+	link.Domain = "go.example.com"
+	decoded, err = decodeLink(models.LinkKey(link.Domain, link.ShortCode), encodeLink(link))
+	require.NoError(t, err)
+	assert.Equal(t, link.Domain, decoded.Domain)
+	assert.Equal(t, link.ShortCode, decoded.ShortCode)
+}
+
+// TestLinkCodec_FormatVersion фиксирует набор полей версии формата из linkKeyPrefix.
+// Тест падает при добавлении поля в encodeLink: если без поля старая запись неверна
+// (доступ к ссылке, лимит переходов, выбор адреса), смените версию префикса, затем
+// обновите ожидание. Так лимит или пароль не теряются при выкатке.
+func TestLinkCodec_FormatVersion(t *testing.T) {
+	startsAt := time.Now()
+	link := testLink()
+	link.PasswordHash = "$2a$10$hash"
+	link.MaxClicks = 1
+	link.StartsAt = &startsAt
+	link.FallbackURL = "https://example.com/archive"
+	link.Title, link.Preview = "Report", true
+	link.GeoRules = models.GeoRules{"DE": "https://example.de"}
+	link.DeviceRules = models.DeviceRules{models.PlatformIOS: {URL: "https://apps.apple.com/app/id123"}}
+	link.Variants = []models.Variant{{Name: "a", URL: "https://a.example.com", Weight: 1}}
+
+	var tags []byte
+	for data := encodeLink(link); len(data) > 0; {
+		tag, _, rest, err := nextField(data)
+		require.NoError(t, err)
+		data = rest
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	assert.Equal(t, "link:v3:", linkKeyPrefix)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}, tags)
+}
+
+// TestLinkCodec_SkipsUnknownFields проверяет совместимость с полями, добавленными позже
+func TestLinkCodec_SkipsUnknownFields(t *testing.T) {
+	link := testLink()
+	data := appendBytesField(encodeLink(link), 200, []byte("future field"))
+
+	decoded, err := decodeLink(link.ShortCode, data)
+	require.NoError(t, err)
+	assert.Equal(t, link.OriginalURL, decoded.OriginalURL)
+}
+
+// TestLinkCodec_Corrupted проверяет обработку обрезанных данных
+func TestLinkCodec_Corrupted(t *testing.T) {
+	data := encodeLink(testLink())
+
+	for _, size := range []int{1, 3, len(data) - 1} {
+		_, err := decodeLink("code", data[:size])
+		assert.ErrorIs(t, err, errCorruptedLink, "size %d", size)
+	}
+}
+
+// TestLinkCodec_Size проверяет, что формат компактнее JSON
+func TestLinkCodec_Size(t *testing.T) {
+	link := testLink()
+	jsonData, err := json.Marshal(link)
+	require.NoError(t, err)
+
+	assert.Less(t, len(encodeLink(link)), len(jsonData)/2)
+}
+
+func BenchmarkLinkCodec_Encode(b *testing.B) {
+	link := testLink()
+	b.ReportAllocs()
+	for b.Loop() {
+		encodeLink(link)
+	}
+}
+
+func BenchmarkLinkCodec_Decode(b *testing.B) {
+	link := testLink()
+	data := encodeLink(link)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		if _, err := decodeLink(link.ShortCode, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLinkJSON_Encode прежний формат кэша для сравнения
+func BenchmarkLinkJSON_Encode(b *testing.B) {
+	link := testLink()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := json.Marshal(link); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLinkJSON_Decode прежний формат кэша для сравнения
+func BenchmarkLinkJSON_Decode(b *testing.B) {
+	data, _ := json.Marshal(testLink())
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		var link models.Link
+		if err := json.Unmarshal(data, &link); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
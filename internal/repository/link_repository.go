@@ -2,11 +2,14 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/SergeiKhy/url-shortener/internal/models"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var (
@@ -16,9 +19,115 @@ var (
 
 type LinkRepository interface {
 	Create(ctx context.Context, link *models.Link) error
-	GetByShortCode(ctx context.Context, code string) (*models.Link, error)
-	Delete(ctx context.Context, code string) error
-	GetLinkIDByShortCode(ctx context.Context, code string) (int64, error)
+	// GetByShortCode возвращает ссылку, в том числе истёкшую, пока её не удалила
+	// очистка: по ней редирект отвечает 410 или переходит на FallbackURL
+	GetByShortCode(ctx context.Context, domain, code string) (*models.Link, error)
+	// GetByShortCodePrimary читает ссылку только с primary: реплика может вернуть
+	// состояние до последнего изменения или удаления, которое нельзя записывать в кэш
+	GetByShortCodePrimary(ctx context.Context, domain, code string) (*models.Link, error)
+	// Update меняет заданные поля ссылки и возвращает её новое состояние
+	Update(ctx context.Context, domain, code string, input *models.UpdateLinkInput) (*models.Link, error)
+	Delete(ctx context.Context, domain, code string) error
+	// ConsumeClick засчитывает переход по ссылке с ограничением числа переходов.
+	// Возвращает false, если лимит исчерпан или ссылки уже нет.
+	ConsumeClick(ctx context.Context, id int64) (bool, error)
+	// ClicksLeft проверяет, не исчерпан ли лимит переходов, не засчитывая переход.
+	// Возвращает false, если лимит исчерпан или ссылки уже нет.
+	ClicksLeft(ctx context.Context, id int64) (bool, error)
+	GetLinkIDByShortCode(ctx context.Context, domain, code string) (int64, error)
+	// GetTopLinks возвращает до limit действующих ссылок с наибольшим числом кликов после since
+	GetTopLinks(ctx context.Context, since time.Time, limit int) ([]*models.Link, error)
+	// PurgeExpired удаляет до limit ссылок, истёкших раньше before, и возвращает удалённые ссылки
+	PurgeExpired(ctx context.Context, before time.Time, limit int, opts PurgeOptions) ([]*models.Link, error)
+}
+
+// PurgeOptions что сохранить при удалении истёкших ссылок
+type PurgeOptions struct {
+	Archive     bool // перенести ссылки в links_archive, их клики — в links_archive_clicks
+	RetireCodes bool // запретить повторную выдачу кодов (retired_codes)
+}
+
+// linkColumns колонки ссылки в порядке, который читает scanLink. Запрос должен
+// обращаться к таблице links без псевдонима: варианты читаются подзапросом по links.id.
+const linkColumns = `id, domain, short_code, original_url, title, preview, redirect_type, password_hash, max_clicks, used_clicks, geo_rules, device_rules, fallback_url, starts_at, expires_at, created_at, ` + linkVariantsColumn
+
+// linkVariantsColumn варианты A/B теста ссылки одним JSON массивом в порядке задания, NULL — вариантов нет
+const linkVariantsColumn = `(
+	SELECT jsonb_agg(jsonb_build_object('name', v.name, 'url', v.url, 'weight', v.weight) ORDER BY v.position)
+	FROM link_variants v WHERE v.link_id = links.id
+)`
+
+// geoRulesParam правила геотаргетинга как параметр JSONB: nil — NULL
+func geoRulesParam(rules models.GeoRules) *string {
+	if rules == nil {
+		return nil
+	}
+	return jsonParam(rules)
+}
+
+// deviceRulesParam правила по устройству как параметр JSONB: nil — NULL
+func deviceRulesParam(rules models.DeviceRules) *string {
+	if rules == nil {
+		return nil
+	}
+	return jsonParam(rules)
+}
+
+func jsonParam(value any) *string {
+	data, _ := json.Marshal(value) // карты строк и структур из строк кодируются всегда
+	param := string(data)
+	return &param
+}
+
+// scanLink читает строку с колонками linkColumns
+func scanLink(row pgx.Row) (*models.Link, error) {
+	link := &models.Link{}
+	err := row.Scan(
+		&link.ID,
+		&link.Domain,
+		&link.ShortCode,
+		&link.OriginalURL,
+		&link.Title,
+		&link.Preview,
+		&link.RedirectType,
+		&link.PasswordHash,
+		&link.MaxClicks,
+		&link.UsedClicks,
+		&link.GeoRules,
+		&link.DeviceRules,
+		&link.FallbackURL,
+		&link.StartsAt,
+		&link.ExpiresAt,
+		&link.CreatedAt,
+		&link.Variants,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// insertVariants сохраняет варианты A/B теста ссылки одним запросом, порядок задаёт position
+func insertVariants(ctx context.Context, tx pgx.Tx, linkID int64, variants []models.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	names := make([]string, len(variants))
+	urls := make([]string, len(variants))
+	weights := make([]int32, len(variants))
+	for i, variant := range variants {
+		names[i], urls[i], weights[i] = variant.Name, variant.URL, int32(variant.Weight)
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO link_variants (link_id, position, name, url, weight)
+		SELECT $1, v.position, v.name, v.url, v.weight
+		FROM unnest($2::text[], $3::text[], $4::integer[]) WITH ORDINALITY AS v(name, url, weight, position)
+	`, linkID, names, urls, weights)
+	if err != nil {
+		return fmt.Errorf("failed to save link variants: %w", err)
+	}
+	return nil
 }
 
 type linkRepository struct {
@@ -30,23 +139,42 @@ func NewLinkRepository(db *PostgresDB) LinkRepository {
 }
 
 func (r *linkRepository) Create(ctx context.Context, link *models.Link) error {
+	// Коды удалённых ссылок из retired_codes не выдаются повторно
 	query := `
-		INSERT INTO links (short_code, original_url, expires_at, created_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO links (domain, short_code, original_url, title, preview, redirect_type, password_hash, max_clicks, geo_rules, device_rules, fallback_url, starts_at, expires_at, created_at)
+		SELECT $1::varchar, $2::varchar, $3::text, $4::text, $5::boolean, $6::smallint, $7::text, $8::integer, COALESCE($9::jsonb, '{}'), COALESCE($10::jsonb, '{}'), $11::text, $12::timestamp, $13::timestamp, $14::timestamp
+		WHERE NOT EXISTS (SELECT 1 FROM retired_codes WHERE domain = $1 AND short_code = $2)
 		RETURNING id, created_at
 	`
 
-	err := r.db.Pool.QueryRow(
-		ctx,
-		query,
-		link.ShortCode,
-		link.OriginalURL,
-		link.ExpiresAt,
-		link.CreatedAt,
-	).Scan(&link.ID, &link.CreatedAt)
+	// Ссылка и её варианты сохраняются вместе: редирект не должен увидеть тест без вариантов
+	err := pgx.BeginFunc(ctx, r.db.Pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(
+			ctx,
+			query,
+			link.Domain,
+			link.ShortCode,
+			link.OriginalURL,
+			link.Title,
+			link.Preview,
+			link.RedirectType,
+			link.PasswordHash,
+			link.MaxClicks,
+			geoRulesParam(link.GeoRules),
+			deviceRulesParam(link.DeviceRules),
+			link.FallbackURL,
+			link.StartsAt,
+			link.ExpiresAt,
+			link.CreatedAt,
+		).Scan(&link.ID, &link.CreatedAt)
+		if err != nil {
+			return err
+		}
+		return insertVariants(ctx, tx, link.ID, link.Variants)
+	})
 
 	if err != nil {
-		if isUniqueViolation(err) {
+		if isUniqueViolation(err) || errors.Is(err, pgx.ErrNoRows) {
 			return ErrCodeExists
 		}
 		return fmt.Errorf("failed to create link: %w", err)
@@ -55,21 +183,21 @@ func (r *linkRepository) Create(ctx context.Context, link *models.Link) error {
 	return nil
 }
 
-func (r *linkRepository) GetByShortCode(ctx context.Context, code string) (*models.Link, error) {
-	query := `
-		SELECT id, short_code, original_url, expires_at, created_at
-		FROM links
-		WHERE short_code = $1 AND (expires_at IS NULL OR expires_at > NOW())
-	`
+// getByShortCodeQuery чтение ссылки по домену и коду
+const getByShortCodeQuery = `
+	SELECT ` + linkColumns + `
+	FROM links
+	WHERE domain = $1 AND short_code = $2
+`
 
-	link := &models.Link{}
-	err := r.db.Pool.QueryRow(ctx, query, code).Scan(
-		&link.ID,
-		&link.ShortCode,
-		&link.OriginalURL,
-		&link.ExpiresAt,
-		&link.CreatedAt,
-	)
+func (r *linkRepository) GetByShortCode(ctx context.Context, domain, code string) (*models.Link, error) {
+	var link *models.Link
+	// Только что созданной ссылки может ещё не быть на реплике — отсутствие проверяем на primary
+	err := r.db.read(ctx, func(pool *pgxpool.Pool) error {
+		var err error
+		link, err = scanLink(pool.QueryRow(ctx, getByShortCodeQuery, domain, code))
+		return err
+	}, isNoRows)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -81,10 +209,70 @@ func (r *linkRepository) GetByShortCode(ctx context.Context, code string) (*mode
 	return link, nil
 }
 
-func (r *linkRepository) Delete(ctx context.Context, code string) error {
-	query := `DELETE FROM links WHERE short_code = $1`
+func (r *linkRepository) GetByShortCodePrimary(ctx context.Context, domain, code string) (*models.Link, error) {
+	link, err := scanLink(r.db.Pool.QueryRow(ctx, getByShortCodeQuery, domain, code))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrLinkNotFound
+		}
+		return nil, fmt.Errorf("failed to get link: %w", err)
+	}
+
+	return link, nil
+}
+
+func (r *linkRepository) Update(ctx context.Context, domain, code string, input *models.UpdateLinkInput) (*models.Link, error) {
+	// NULL в параметре оставляет поле без изменений
+	query := `
+		UPDATE links
+		SET redirect_type = COALESCE($3::smallint, redirect_type),
+			password_hash = COALESCE($4::text, password_hash),
+			max_clicks = COALESCE($5::integer, max_clicks),
+			fallback_url = COALESCE($6::text, fallback_url),
+			title = COALESCE($7::text, title),
+			preview = COALESCE($8::boolean, preview),
+			geo_rules = COALESCE($9::jsonb, geo_rules),
+			device_rules = COALESCE($10::jsonb, device_rules)
+		WHERE domain = $1 AND short_code = $2
+		RETURNING ` + linkColumns
+
+	var link *models.Link
+	err := pgx.BeginFunc(ctx, r.db.Pool, func(tx pgx.Tx) error {
+		// Варианты заменяются целиком до UPDATE: RETURNING читает уже новые
+		if input.Variants != nil {
+			var linkID int64
+			err := tx.QueryRow(ctx, `SELECT id FROM links WHERE domain = $1 AND short_code = $2 FOR UPDATE`, domain, code).Scan(&linkID)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `DELETE FROM link_variants WHERE link_id = $1`, linkID); err != nil {
+				return fmt.Errorf("failed to delete link variants: %w", err)
+			}
+			if err := insertVariants(ctx, tx, linkID, input.Variants); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		link, err = scanLink(tx.QueryRow(ctx, query, domain, code,
+			input.RedirectType, input.PasswordHash, input.MaxClicks, input.FallbackURL, input.Title, input.Preview, geoRulesParam(input.GeoRules),
+			deviceRulesParam(input.DeviceRules)))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrLinkNotFound
+		}
+		return nil, fmt.Errorf("failed to update link: %w", err)
+	}
+
+	return link, nil
+}
+
+func (r *linkRepository) Delete(ctx context.Context, domain, code string) error {
+	query := `DELETE FROM links WHERE domain = $1 AND short_code = $2`
 
-	result, err := r.db.Pool.Exec(ctx, query, code)
+	result, err := r.db.Pool.Exec(ctx, query, domain, code)
 	if err != nil {
 		return fmt.Errorf("failed to delete link: %w", err)
 	}
@@ -96,11 +284,44 @@ func (r *linkRepository) Delete(ctx context.Context, code string) error {
 	return nil
 }
 
-func (r *linkRepository) GetLinkIDByShortCode(ctx context.Context, code string) (int64, error) {
-	query := `SELECT id FROM links WHERE short_code = $1`
+func (r *linkRepository) ConsumeClick(ctx context.Context, id int64) (bool, error) {
+	// Условие и инкремент выполняются одним UPDATE под блокировкой строки:
+	// из одновременных переходов по одноразовой ссылке засчитывается только один
+	query := `
+		UPDATE links
+		SET used_clicks = used_clicks + 1
+		WHERE id = $1 AND used_clicks < max_clicks
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume link click: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *linkRepository) ClicksLeft(ctx context.Context, id int64) (bool, error) {
+	// Читается с primary: реплика может отставать от только что засчитанного перехода
+	query := `SELECT used_clicks < max_clicks FROM links WHERE id = $1`
+
+	var left bool
+	err := r.db.Pool.QueryRow(ctx, query, id).Scan(&left)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check link clicks: %w", err)
+	}
+
+	return left, nil
+}
+
+func (r *linkRepository) GetLinkIDByShortCode(ctx context.Context, domain, code string) (int64, error) {
+	query := `SELECT id FROM links WHERE domain = $1 AND short_code = $2`
 
 	var linkID int64
-	err := r.db.Pool.QueryRow(ctx, query, code).Scan(&linkID)
+	err := r.db.Pool.QueryRow(ctx, query, domain, code).Scan(&linkID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return 0, ErrLinkNotFound
@@ -111,6 +332,114 @@ func (r *linkRepository) GetLinkIDByShortCode(ctx context.Context, code string)
 	return linkID, nil
 }
 
+func (r *linkRepository) GetTopLinks(ctx context.Context, since time.Time, limit int) ([]*models.Link, error) {
+	query := `
+		SELECT ` + linkColumns + `
+		FROM (
+			SELECT link_id, COUNT(*) AS clicks
+			FROM clicks
+			WHERE clicked_at >= $1
+			GROUP BY link_id
+		) AS top
+		JOIN links ON links.id = top.link_id
+		WHERE expires_at IS NULL OR expires_at > NOW()
+		ORDER BY top.clicks DESC
+		LIMIT $2
+	`
+
+	var links []*models.Link
+	err := r.db.read(ctx, func(pool *pgxpool.Pool) error {
+		links = nil
+		rows, err := pool.Query(ctx, query, since, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			link, err := scanLink(rows)
+			if err != nil {
+				return err
+			}
+			links = append(links, link)
+		}
+		return rows.Err()
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top links: %w", err)
+	}
+
+	return links, nil
+}
+
+func (r *linkRepository) PurgeExpired(ctx context.Context, before time.Time, limit int, opts PurgeOptions) ([]*models.Link, error) {
+	// SKIP LOCKED: пакет не ждёт строк, которые сейчас изменяются запросами API
+	query := `
+		WITH expired AS (
+			SELECT id FROM links
+			WHERE expires_at < $1
+			ORDER BY expires_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		), deleted AS (
+			DELETE FROM links l
+			USING expired e
+			WHERE l.id = e.id
+			RETURNING l.*
+		)`
+	if opts.Archive {
+		// Все части запроса видят снимок до удаления, поэтому клики и варианты, которые
+		// удаляются каскадно, ещё доступны для копирования в архив
+		query += `, archived AS (
+			INSERT INTO links_archive (
+				id, domain, short_code, original_url, title, preview, redirect_type, password_hash,
+				max_clicks, used_clicks, geo_rules, device_rules, variants, fallback_url,
+				starts_at, expires_at, created_at
+			)
+			SELECT d.id, d.domain, d.short_code, d.original_url, d.title, d.preview, d.redirect_type, d.password_hash,
+				d.max_clicks, d.used_clicks, d.geo_rules, d.device_rules, (
+					SELECT jsonb_agg(jsonb_build_object('name', v.name, 'url', v.url, 'weight', v.weight) ORDER BY v.position)
+					FROM link_variants v WHERE v.link_id = d.id
+				), d.fallback_url, d.starts_at, d.expires_at, d.created_at
+			FROM deleted d
+		), archived_clicks AS (
+			INSERT INTO links_archive_clicks (id, link_id, ip_address, user_agent, referer, country, rule, variant, clicked_at)
+			SELECT c.id, c.link_id, c.ip_address, c.user_agent, c.referer, c.country, c.rule, c.variant, c.clicked_at
+			FROM clicks c JOIN deleted d ON d.id = c.link_id
+		)`
+	}
+	if opts.RetireCodes {
+		query += `, retired AS (
+			INSERT INTO retired_codes (domain, short_code)
+			SELECT domain, short_code FROM deleted
+			ON CONFLICT DO NOTHING
+		)`
+	}
+	query += `
+		SELECT id, domain, short_code, original_url, expires_at, created_at FROM deleted`
+
+	rows, err := r.db.Pool.Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge expired links: %w", err)
+	}
+
+	links, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Link, error) {
+		link := &models.Link{}
+		err := row.Scan(&link.ID, &link.Domain, &link.ShortCode, &link.OriginalURL, &link.ExpiresAt, &link.CreatedAt)
+		return link, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge expired links: %w", err)
+	}
+
+	return links, nil
+}
+
+// isNoRows признак пустого результата, который на реплике может означать задержку репликации
+func isNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
+
 // Проверка на уникальность
 func isUniqueViolation(err error) bool {
 	// Для pgx v5 проверяем код ошибки
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// publishTimeout ограничивает публикацию инвалидации, чтобы недоступный Redis не задерживал удаление
+const publishTimeout = 500 * time.Millisecond
+
+// LocalCacheConfig конфигурация локального кэша
+type LocalCacheConfig struct {
+	Size int           // Максимум ссылок в памяти
+	TTL  time.Duration // Время жизни записи
+	// Channel канал pub/sub, через который инстансы сообщают друг другу об удалённых ссылках
+	Channel string
+}
+
+// LocalCache L1 кэш ссылок в памяти процесса перед CacheRepository (Redis).
+// Самые популярные ссылки отдаются без сетевого запроса и десериализации.
+// Удаление публикуется в Redis pub/sub, и каждый инстанс убирает ссылку из своей памяти.
+type LocalCache struct {
+	next   CacheRepository
+	local  *expirable.LRU[string, *models.Link]
+	redis  *RedisDB
+	config LocalCacheConfig
+	logger *zap.Logger
+
+	// mu и generation защищают от заполнения памяти устаревшей ссылкой: если между чтением
+	// из Redis и записью в память пришла инвалидация, прочитанное значение не сохраняется
+	mu         sync.Mutex
+	generation uint64
+
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+// NewLocalCache создаёт локальный кэш. Если redis равен nil, инвалидация
+// выполняется только в текущем процессе (подходит для одного инстанса и тестов).
+func NewLocalCache(next CacheRepository, redis *RedisDB, config LocalCacheConfig, logger *zap.Logger) *LocalCache {
+	return &LocalCache{
+		next:   next,
+		local:  expirable.NewLRU[string, *models.Link](config.Size, nil, config.TTL),
+		redis:  redis,
+		config: config,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *LocalCache) Get(ctx context.Context, key string) (*models.Link, error) {
+	if link, ok := c.local.Get(key); ok {
+		if link.ExpiresAt == nil || time.Now().Before(*link.ExpiresAt) {
+			metrics.LocalCacheRequests.WithLabelValues(metrics.ResultHit).Inc()
+			return copyLink(link), nil
+		}
+		// Ссылка истекла, пока лежала в памяти
+		c.local.Remove(key)
+	}
+	metrics.LocalCacheRequests.WithLabelValues(metrics.ResultMiss).Inc()
+
+	// Negative-записи в памяти не храним: иначе созданная на другом инстансе ссылка
+	// оставалась бы «несуществующей» здесь до истечения TTL
+	generation := c.currentGeneration()
+	link, err := c.next.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.local.Add(key, copyLink(link))
+	}
+	c.mu.Unlock()
+	return link, nil
+}
+
+func (c *LocalCache) Set(ctx context.Context, key string, link *models.Link, ttl time.Duration) error {
+	c.local.Add(key, copyLink(link))
+	return c.next.Set(ctx, key, link, ttl)
+}
+
+func (c *LocalCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	c.invalidate(key)
+	return c.next.SetNotFound(ctx, key, ttl)
+}
+
+func (c *LocalCache) Delete(ctx context.Context, key string) error {
+	c.invalidate(key)
+	err := c.next.Delete(ctx, key)
+	c.publishInvalidation(ctx, key)
+	return err
+}
+
+// Len количество ссылок в памяти
+func (c *LocalCache) Len() int {
+	return c.local.Len()
+}
+
+// Start подписывается на канал инвалидации. Подписка переживает переподключения к Redis.
+func (c *LocalCache) Start() {
+	if c.redis == nil {
+		return
+	}
+
+	c.pubsub = c.redis.Client.Subscribe(context.Background(), c.config.Channel)
+	go c.listen(c.pubsub.ChannelWithSubscriptions())
+}
+
+// Close отписывается от канала инвалидации
+func (c *LocalCache) Close() error {
+	if c.pubsub == nil {
+		return nil
+	}
+	err := c.pubsub.Close()
+	<-c.done
+	return err
+}
+
+// listen удаляет ссылки по сообщениям из канала
+func (c *LocalCache) listen(ch <-chan interface{}) {
+	defer close(c.done)
+
+	for msg := range ch {
+		switch msg := msg.(type) {
+		case *redis.Message:
+			c.invalidate(msg.Payload)
+			metrics.LocalCacheInvalidations.Inc()
+		case *redis.Subscription:
+			// Подписка установлена заново после разрыва соединения: сообщения,
+			// пришедшие за это время, потеряны, поэтому сбрасываем кэш целиком
+			if msg.Kind == "subscribe" {
+				c.invalidate("")
+				c.logger.Debug("Subscribed to cache invalidation channel", zap.String("channel", msg.Channel))
+			}
+		}
+	}
+}
+
+// currentGeneration номер последней инвалидации
+func (c *LocalCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// invalidate убирает ссылку из памяти (пустой ключ — все ссылки) и отменяет заполнения
+// памяти, которые начались до инвалидации. Номер поколения общий для всех ключей: лишний
+// промах редкого параллельного чтения дешевле, чем учёт поколения каждой ссылки.
+func (c *LocalCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if key == "" {
+		c.local.Purge()
+		return
+	}
+	c.local.Remove(key)
+}
+
+// publishInvalidation сообщает остальным инстансам об удалении ссылки
+func (c *LocalCache) publishInvalidation(ctx context.Context, key string) {
+	if c.redis == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+	defer cancel()
+
+	if err := c.redis.Client.Publish(ctx, c.config.Channel, key).Err(); err != nil {
+		// Другие инстансы отдадут ссылку из памяти не дольше LocalCacheConfig.TTL
+		c.logger.Warn("Failed to publish cache invalidation", zap.String("code", key), zap.Error(err))
+	}
+}
+
+// copyLink защищает запись в памяти от изменений вызывающим кодом
+func copyLink(link *models.Link) *models.Link {
+	linkCopy := *link
+	return &linkCopy
+}
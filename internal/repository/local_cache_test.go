@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestLocalCache(next CacheRepository) *LocalCache {
+	return NewLocalCache(next, nil, LocalCacheConfig{Size: 10, TTL: time.Minute}, zap.NewNop())
+}
+
+// TestLocalCache_ServesHitsFromMemory проверяет, что повторные чтения не доходят до Redis
+func TestLocalCache_ServesHitsFromMemory(t *testing.T) {
+	next := &flakyCache{}
+	cache := newTestLocalCache(next)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		link, err := cache.Get(ctx, "code")
+		require.NoError(t, err)
+		assert.Equal(t, "code", link.ShortCode)
+	}
+	assert.Equal(t, int32(1), next.calls.Load())
+
+	// Изменение возвращённой ссылки не портит запись в памяти
+	link, _ := cache.Get(ctx, "code")
+	link.ShortCode = "changed"
+	link, _ = cache.Get(ctx, "code")
+	assert.Equal(t, "code", link.ShortCode)
+}
+
+// TestLocalCache_Delete проверяет удаление из памяти и из следующего уровня
+func TestLocalCache_Delete(t *testing.T) {
+	next := &flakyCache{}
+	cache := newTestLocalCache(next)
+	ctx := context.Background()
+
+	require.NoError(t, cache.Set(ctx, "code", &models.Link{ShortCode: "code"}, time.Hour))
+	assert.Equal(t, 1, cache.Len())
+
+	require.NoError(t, cache.Delete(ctx, "code"))
+	assert.Equal(t, 0, cache.Len())
+	assert.Equal(t, int32(2), next.calls.Load())
+}
+
+// TestLocalCache_SkipsExpiredLinks проверяет, что истёкшая ссылка не отдаётся из памяти
+func TestLocalCache_SkipsExpiredLinks(t *testing.T) {
+	next := &flakyCache{}
+	next.setErr(ErrCacheMiss)
+	cache := newTestLocalCache(next)
+	ctx := context.Background()
+
+	expired := time.Now().Add(-time.Second)
+	cache.local.Add("code", &models.Link{ShortCode: "code", ExpiresAt: &expired})
+
+	_, err := cache.Get(ctx, "code")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+	assert.Equal(t, 0, cache.Len())
+}
+
+// blockingCache кэш, чтение из которого ждёт сигнала
+type blockingCache struct {
+	flakyCache
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingCache) Get(ctx context.Context, key string) (*models.Link, error) {
+	close(b.started)
+	<-b.release
+	return b.flakyCache.Get(ctx, key)
+}
+
+// TestLocalCache_InvalidationDuringFill проверяет, что ссылка, прочитанная из Redis до
+// инвалидации, не сохраняется в памяти
+func TestLocalCache_InvalidationDuringFill(t *testing.T) {
+	next := &blockingCache{started: make(chan struct{}), release: make(chan struct{})}
+	cache := newTestLocalCache(next)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		link, err := cache.Get(context.Background(), "code")
+		assert.NoError(t, err)
+		assert.NotNil(t, link)
+	}()
+
+	// Сообщение об удалении приходит, пока чтение из Redis ещё выполняется
+	<-next.started
+	cache.invalidate("code")
+	close(next.release)
+	<-done
+
+	assert.Equal(t, 0, cache.Len())
+}
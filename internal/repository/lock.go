@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+)
+
+// Locker выполняет фоновую задачу, только если её не выполняет другой инстанс
+type Locker interface {
+	// TryLock выполняет fn под блокировкой name. Если блокировка занята, fn не вызывается
+	// и возвращается false.
+	TryLock(ctx context.Context, name string, fn func(ctx context.Context) error) (bool, error)
+}
+
+// advisoryLocker блокировки на session advisory lock PostgreSQL: при падении инстанса
+// блокировка снимается вместе с соединением
+type advisoryLocker struct {
+	db *PostgresDB
+}
+
+func NewAdvisoryLocker(db *PostgresDB) Locker {
+	return &advisoryLocker{db: db}
+}
+
+func (l *advisoryLocker) TryLock(ctx context.Context, name string, fn func(ctx context.Context) error) (bool, error) {
+	conn, err := l.db.Pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	key := lockKey(name)
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&locked); err != nil {
+		return false, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	if !locked {
+		return false, nil
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, key)
+
+	return true, fn(ctx)
+}
+
+// lockKey ключ advisory lock по имени задачи
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("url-shortener:" + name))
+	return int64(h.Sum64())
+}
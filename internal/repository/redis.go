@@ -2,36 +2,150 @@ package repository
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
+	"os"
 	"time"
 
 	"github.com/SergeiKhy/url-shortener/internal/config"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
+// Топологии Redis
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
+// RedisDB клиент Redis. UniversalClient скрывает топологию: один узел, Sentinel или Cluster.
 type RedisDB struct {
-	Client *redis.Client
+	Client redis.UniversalClient
 }
 
+// NewRedisClient создаёт клиент Redis и проверяет подключение
 func NewRedisClient(cfg config.RedisConfig) (*RedisDB, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:         fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
-		Password:     "",
-		DB:           0,
-		PoolSize:     100,
-		MinIdleConns: 10,
-	})
+	db, err := NewLazyRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := client.Ping(ctx).Err(); err != nil {
+	if err := db.Ping(ctx); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
+	return db, nil
+}
+
+// NewLazyRedisClient создаёт клиент Redis без проверки подключения.
+// Соединения устанавливаются при первой команде, поэтому клиент можно создать,
+// пока Redis недоступен, и он переподключится сам.
+func NewLazyRedisClient(cfg config.RedisConfig) (*RedisDB, error) {
+	opts, err := redisOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var client redis.UniversalClient
+	switch cfg.Mode {
+	case RedisModeSentinel:
+		client = redis.NewFailoverClient(opts.Failover())
+	case RedisModeCluster:
+		client = redis.NewClusterClient(opts.Cluster())
+	default:
+		client = redis.NewClient(opts.Simple())
+	}
+
+	// Спаны OpenTelemetry для каждой команды
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to instrument Redis client: %w", err)
+	}
+
 	return &RedisDB{Client: client}, nil
 }
 
+// redisOptions собирает параметры клиента и проверяет их согласованность с топологией
+func redisOptions(cfg config.RedisConfig) (*redis.UniversalOptions, error) {
+	opts := &redis.UniversalOptions{
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		PoolSize:     100,
+		MinIdleConns: 10,
+		// Дедлайн контекста ограничивает сетевые операции (таймауты circuit breaker)
+		ContextTimeoutEnabled: true,
+	}
+
+	switch cfg.Mode {
+	case "", RedisModeStandalone:
+		opts.Addrs = []string{net.JoinHostPort(cfg.Host, cfg.Port)}
+	case RedisModeSentinel:
+		if cfg.MasterName == "" || len(cfg.Addrs) == 0 {
+			return nil, errors.New("redis sentinel mode requires a master name and sentinel addresses")
+		}
+		opts.Addrs = cfg.Addrs
+		opts.MasterName = cfg.MasterName
+		opts.SentinelUsername = cfg.SentinelUsername
+		opts.SentinelPassword = cfg.SentinelPassword
+	case RedisModeCluster:
+		if len(cfg.Addrs) == 0 {
+			return nil, errors.New("redis cluster mode requires node addresses")
+		}
+		if cfg.DB != 0 {
+			return nil, errors.New("redis cluster supports only DB 0")
+		}
+		opts.Addrs = cfg.Addrs
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", cfg.Mode)
+	}
+
+	if cfg.TLSEnabled {
+		tlsConfig, err := redisTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	return opts, nil
+}
+
+// redisTLSConfig настраивает TLS с опциональным собственным CA
+func redisTLSConfig(cfg config.RedisConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.TLSServerName,
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Redis CA file %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// Ping проверяет доступность Redis
+func (db *RedisDB) Ping(ctx context.Context) error {
+	return db.Client.Ping(ctx).Err()
+}
+
 func (db *RedisDB) Close() error {
 	return db.Client.Close()
 }
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRedisOptions проверяет параметры клиента для каждой топологии
+func TestRedisOptions(t *testing.T) {
+	t.Run("standalone с ACL", func(t *testing.T) {
+		opts, err := redisOptions(config.RedisConfig{
+			Host: "redis", Port: "6379", Username: "app", Password: "secret", DB: 2,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"redis:6379"}, opts.Addrs)
+		assert.Equal(t, "app", opts.Username)
+		assert.Equal(t, "secret", opts.Password)
+		assert.Equal(t, 2, opts.DB)
+		assert.Nil(t, opts.TLSConfig)
+	})
+
+	t.Run("sentinel", func(t *testing.T) {
+		opts, err := redisOptions(config.RedisConfig{
+			Mode:             RedisModeSentinel,
+			Addrs:            []string{"s1:26379", "s2:26379"},
+			MasterName:       "mymaster",
+			SentinelPassword: "sentinel-secret",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "mymaster", opts.Failover().MasterName)
+		assert.Equal(t, []string{"s1:26379", "s2:26379"}, opts.Failover().SentinelAddrs)
+		assert.Equal(t, "sentinel-secret", opts.Failover().SentinelPassword)
+	})
+
+	t.Run("sentinel без master", func(t *testing.T) {
+		_, err := redisOptions(config.RedisConfig{Mode: RedisModeSentinel, Addrs: []string{"s1:26379"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("cluster с DB отличной от 0", func(t *testing.T) {
+		_, err := redisOptions(config.RedisConfig{Mode: RedisModeCluster, Addrs: []string{"n1:6379"}, DB: 1})
+		assert.Error(t, err)
+	})
+
+	t.Run("неизвестный режим", func(t *testing.T) {
+		_, err := redisOptions(config.RedisConfig{Mode: "replicated"})
+		assert.Error(t, err)
+	})
+}
+
+// TestRedisOptions_TLS проверяет загрузку собственного CA
+func TestRedisOptions_TLS(t *testing.T) {
+	caFile := writeTestCA(t)
+
+	opts, err := redisOptions(config.RedisConfig{
+		Host: "redis", Port: "6380",
+		TLSEnabled: true, TLSCAFile: caFile, TLSServerName: "redis.internal",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, opts.TLSConfig)
+	assert.NotNil(t, opts.TLSConfig.RootCAs)
+	assert.Equal(t, "redis.internal", opts.TLSConfig.ServerName)
+
+	// Файл без сертификатов
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("not a certificate"), 0o600))
+	_, err = redisOptions(config.RedisConfig{Host: "redis", Port: "6380", TLSEnabled: true, TLSCAFile: empty})
+	assert.Error(t, err)
+}
+
+// writeTestCA создаёт самоподписанный сертификат CA во временном файле
+func writeTestCA(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return path
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/config"
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// replica пул соединений одной реплики и её состояние
+type replica struct {
+	name    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// replicaSet реплики для чтения с фоновой проверкой доступности
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	interval time.Duration
+	logger   *zap.Logger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newReplicaSet(cfg config.DBConfig, logger *zap.Logger) (*replicaSet, error) {
+	set := &replicaSet{
+		interval: valueOrDefault(cfg.ReplicaCheckInterval, defaultReplicaCheckInterval),
+		logger:   logger,
+		stop:     make(chan struct{}),
+	}
+
+	for i, dsn := range cfg.ReplicaDSNs {
+		pool, err := newPool(dsn, cfg)
+		if err != nil {
+			set.close()
+			return nil, fmt.Errorf("replica %d: %w", i, err)
+		}
+		set.replicas = append(set.replicas, &replica{
+			name: fmt.Sprintf("replica-%d", i),
+			pool: pool,
+		})
+	}
+
+	// Недоступная при старте реплика не мешает запуску: чтение идёт с primary,
+	// пока фоновая проверка не увидит её снова
+	set.checkAll()
+
+	set.wg.Add(1)
+	go set.checkLoop()
+
+	return set, nil
+}
+
+// pick возвращает следующую доступную реплику (round robin по доступным) или nil
+func (s *replicaSet) pick() *replica {
+	healthy := 0
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			healthy++
+		}
+	}
+	if healthy == 0 {
+		return nil
+	}
+
+	target := int(s.next.Add(1) % uint64(healthy))
+	for _, r := range s.replicas {
+		if !r.healthy.Load() {
+			continue
+		}
+		if target == 0 {
+			return r
+		}
+		target--
+	}
+	// Реплика стала недоступна между проходами — читаем с primary
+	return nil
+}
+
+// markDown исключает реплику из чтения до следующей успешной проверки
+func (s *replicaSet) markDown(r *replica, err error) {
+	if r.healthy.CompareAndSwap(true, false) {
+		metrics.DBReplicaUp.WithLabelValues(r.name).Set(0)
+		s.logger.Warn("Реплика БД недоступна, чтение переключено на primary",
+			zap.String("replica", r.name),
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *replicaSet) checkLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.checkAll()
+		}
+	}
+}
+
+// checkAll пингует реплики и обновляет их состояние
+func (s *replicaSet) checkAll() {
+	for _, r := range s.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+		err := r.pool.Ping(ctx)
+		cancel()
+
+		if err != nil {
+			s.markDown(r, err)
+			continue
+		}
+		if r.healthy.CompareAndSwap(false, true) {
+			metrics.DBReplicaUp.WithLabelValues(r.name).Set(1)
+			s.logger.Info("Реплика БД доступна", zap.String("replica", r.name))
+		}
+	}
+}
+
+func (s *replicaSet) close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.wg.Wait()
+	for _, r := range s.replicas {
+		r.pool.Close()
+	}
+}
+
+// read выполняет запрос чтения на реплике, а если реплик нет или реплика не отвечает — на primary.
+// stale (может быть nil) сообщает, что ответ реплики мог устареть из-за задержки репликации
+// и запрос нужно подтвердить на primary.
+func (db *PostgresDB) read(ctx context.Context, fn func(pool *pgxpool.Pool) error, stale func(error) bool) error {
+	if db.replicas == nil {
+		return fn(db.Pool)
+	}
+
+	r := db.replicas.pick()
+	if r == nil {
+		metrics.DBReads.WithLabelValues(metrics.DBTargetPrimary).Inc()
+		return fn(db.Pool)
+	}
+
+	err := fn(r.pool)
+	switch {
+	case err == nil:
+		metrics.DBReads.WithLabelValues(metrics.DBTargetReplica).Inc()
+		return nil
+	case stale != nil && stale(err):
+		metrics.DBReads.WithLabelValues(metrics.DBTargetPrimary).Inc()
+		return fn(db.Pool)
+	case isConnectionError(ctx, err):
+		db.replicas.markDown(r, err)
+		metrics.DBReads.WithLabelValues(metrics.DBTargetPrimary).Inc()
+		return fn(db.Pool)
+	default:
+		metrics.DBReads.WithLabelValues(metrics.DBTargetReplica).Inc()
+		return err
+	}
+}
+
+// isConnectionError отличает недоступность сервера от ошибок самого запроса,
+// которые повторились бы и на primary
+func isConnectionError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		pgconn.SafeToRetry(err) ||
+		pgconn.Timeout(err)
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// TestReplicaSet_Pick проверяет round robin только по доступным репликам
+func TestReplicaSet_Pick(t *testing.T) {
+	set := &replicaSet{logger: zap.NewNop()}
+	for i := 0; i < 3; i++ {
+		set.replicas = append(set.replicas, &replica{name: fmt.Sprintf("replica-%d", i)})
+	}
+	assert.Nil(t, set.pick(), "недоступные реплики не выбираются")
+
+	set.replicas[0].healthy.Store(true)
+	set.replicas[2].healthy.Store(true)
+
+	picked := map[string]int{}
+	for i := 0; i < 10; i++ {
+		picked[set.pick().name]++
+	}
+	assert.Equal(t, 5, picked["replica-0"])
+	assert.Equal(t, 5, picked["replica-2"])
+
+	set.markDown(set.replicas[0], errors.New("connection refused"))
+	assert.Equal(t, "replica-2", set.pick().name)
+}
+
+// TestIsConnectionError проверяет, какие ошибки переключают чтение на primary
+func TestIsConnectionError(t *testing.T) {
+	ctx := context.Background()
+
+	assert.True(t, isConnectionError(ctx, &pgconn.ConnectError{}))
+	assert.True(t, isConnectionError(ctx, fmt.Errorf("read: %w", io.ErrUnexpectedEOF)))
+
+	// Ошибки запроса повторились бы и на primary
+	assert.False(t, isConnectionError(ctx, pgx.ErrNoRows))
+	assert.False(t, isConnectionError(ctx, &pgconn.PgError{Code: "42P01"}))
+
+	// Запрос отменён клиентом
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.False(t, isConnectionError(cancelled, &pgconn.ConnectError{}))
+}
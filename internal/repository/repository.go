@@ -3,40 +3,54 @@ package repository
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/SergeiKhy/url-shortener/internal/config"
+	"github.com/exaring/otelpgx"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
 )
 
+// Параметры пула соединений по умолчанию
+const (
+	defaultMaxConns             = 25
+	defaultMinConns             = 5
+	defaultMaxConnLifetime      = time.Hour
+	defaultMaxConnIdleTime      = 30 * time.Minute
+	defaultHealthCheckPeriod    = time.Minute
+	defaultReplicaCheckInterval = 5 * time.Second
+)
+
+// PostgresDB пул соединений с primary и, опционально, пулы реплик для чтения
 type PostgresDB struct {
 	Pool *pgxpool.Pool
-}
 
-func NewPostgresDB(cfg config.DBConfig) (*PostgresDB, error) {
-	dsn := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.User,
-		cfg.Password,
-		cfg.Host,
-		cfg.Port,
-		cfg.Name,
-	)
+	replicas *replicaSet
+}
 
-	poolConfig, err := pgxpool.ParseConfig(dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse DB config: %w", err)
+func NewPostgresDB(cfg config.DBConfig, logger *zap.Logger) (*PostgresDB, error) {
+	query := url.Values{}
+	sslMode := cfg.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	query.Set("sslmode", sslMode)
+	if cfg.SSLRootCert != "" {
+		query.Set("sslrootcert", cfg.SSLRootCert)
 	}
 
-	// Настрока пула соединений
-	poolConfig.MaxConns = 25
-	poolConfig.MinConns = 5
-	poolConfig.MaxConnLifetime = time.Hour
-	poolConfig.MaxConnIdleTime = 30 * time.Minute
+	dsn := (&url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
+		Path:     cfg.Name,
+		RawQuery: query.Encode(),
+	}).String()
 
-	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	pool, err := newPool(dsn, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+		return nil, err
 	}
 
 	// Проверка подключения
@@ -44,12 +58,74 @@ func NewPostgresDB(cfg config.DBConfig) (*PostgresDB, error) {
 	defer cancel()
 
 	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &PostgresDB{Pool: pool}, nil
+	db := &PostgresDB{Pool: pool}
+
+	if len(cfg.ReplicaDSNs) > 0 {
+		db.replicas, err = newReplicaSet(cfg, logger)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// newPool создаёт пул соединений с настройками из конфигурации
+func newPool(dsn string, cfg config.DBConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DB config: %w", err)
+	}
+
+	// Настройка пула соединений
+	poolConfig.MaxConns = valueOrDefault(cfg.MaxConns, defaultMaxConns)
+	poolConfig.MinConns = valueOrDefault(cfg.MinConns, defaultMinConns)
+	poolConfig.MaxConnLifetime = valueOrDefault(cfg.MaxConnLifetime, defaultMaxConnLifetime)
+	poolConfig.MaxConnIdleTime = valueOrDefault(cfg.MaxConnIdleTime, defaultMaxConnIdleTime)
+	poolConfig.HealthCheckPeriod = valueOrDefault(cfg.HealthCheckPeriod, defaultHealthCheckPeriod)
+
+	// Спаны OpenTelemetry для каждого запроса
+	poolConfig.ConnConfig.Tracer = otelpgx.NewTracer(otelpgx.WithTrimSQLInSpanName())
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+
+	return pool, nil
+}
+
+// Ping проверяет доступность БД
+func (db *PostgresDB) Ping(ctx context.Context) error {
+	return db.Pool.Ping(ctx)
+}
+
+// Pools возвращает пулы по именам (primary и реплики) для экспорта метрик
+func (db *PostgresDB) Pools() map[string]*pgxpool.Pool {
+	pools := map[string]*pgxpool.Pool{"primary": db.Pool}
+	if db.replicas != nil {
+		for _, r := range db.replicas.replicas {
+			pools[r.name] = r.pool
+		}
+	}
+	return pools
 }
 
 func (db *PostgresDB) Close() {
+	if db.replicas != nil {
+		db.replicas.close()
+	}
 	db.Pool.Close()
 }
+
+func valueOrDefault[T int32 | time.Duration](value, def T) T {
+	if value <= 0 {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/SergeiKhy/url-shortener/internal/repository"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrWarmupInProgress прогрев кэша уже выполняется
+var ErrWarmupInProgress = errors.New("cache warm-up already in progress")
+
+// Константы прогрева кэша по умолчанию
+const (
+	defaultWarmupTopN        = 1000
+	defaultWarmupWindow      = 24 * time.Hour
+	defaultWarmupConcurrency = 8
+)
+
+// CacheWarmerConfig конфигурация прогрева кэша
+type CacheWarmerConfig struct {
+	TopN        int           // Сколько самых популярных ссылок загружать
+	Window      time.Duration // За какой период считать клики
+	Concurrency int           // Максимум параллельных записей в кэш
+}
+
+// WarmupResult итог прогрева кэша
+type WarmupResult struct {
+	Total      int     `json:"total"`
+	Loaded     int     `json:"loaded"`
+	Failed     int     `json:"failed"`
+	Skipped    int     `json:"skipped"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// CacheWarmer загружает самые популярные ссылки в кэш, чтобы после деплоя
+// или очистки Redis первая волна трафика не уходила в PostgreSQL
+type CacheWarmer interface {
+	Warmup(ctx context.Context) (*WarmupResult, error)
+}
+
+// cacheWarmer реализация прогрева кэша
+type cacheWarmer struct {
+	linkRepo  repository.LinkRepository
+	cacheRepo repository.CacheRepository
+	config    CacheWarmerConfig
+	logger    *zap.Logger
+	running   atomic.Bool
+}
+
+// NewCacheWarmer создаёт новый экземпляр прогрева кэша
+func NewCacheWarmer(
+	linkRepo repository.LinkRepository,
+	cacheRepo repository.CacheRepository,
+	config CacheWarmerConfig,
+	logger *zap.Logger,
+) CacheWarmer {
+	if config.TopN <= 0 {
+		config.TopN = defaultWarmupTopN
+	}
+	if config.Window <= 0 {
+		config.Window = defaultWarmupWindow
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultWarmupConcurrency
+	}
+
+	return &cacheWarmer{
+		linkRepo:  linkRepo,
+		cacheRepo: cacheRepo,
+		config:    config,
+		logger:    logger,
+	}
+}
+
+// Warmup загружает TopN ссылок с наибольшим числом кликов за Window в кэш.
+// Одновременно выполняется только один прогрев.
+func (w *cacheWarmer) Warmup(ctx context.Context) (*WarmupResult, error) {
+	if !w.running.CompareAndSwap(false, true) {
+		return nil, ErrWarmupInProgress
+	}
+	defer w.running.Store(false)
+
+	start := time.Now()
+	links, err := w.linkRepo.GetTopLinks(ctx, start.Add(-w.config.Window), w.config.TopN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load top links: %w", err)
+	}
+
+	w.logger.Info("Прогрев кэша запущен",
+		zap.Int("links", len(links)),
+		zap.Duration("window", w.config.Window),
+		zap.Int("concurrency", w.config.Concurrency),
+	)
+
+	var loaded, failed, skipped atomic.Int64
+	var progressMu sync.Mutex
+	reportEvery := max(len(links)/10, 1)
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(w.config.Concurrency)
+
+	for _, link := range links {
+		if groupCtx.Err() != nil {
+			break
+		}
+		group.Go(func() error {
+			key := models.LinkKey(link.Domain, link.ShortCode)
+			// Между выбором популярных ссылок (возможно, с отстающей реплики) и записью в кэш
+			// ссылку могли изменить или удалить, а кэш — уже очистить. Set перезаписывает ключ,
+			// поэтому в кэш пишется текущее состояние ссылки с primary.
+			current, err := w.linkRepo.GetByShortCodePrimary(groupCtx, link.Domain, link.ShortCode)
+			if err == nil {
+				err = w.cacheRepo.Set(groupCtx, key, current, cacheTTL(current))
+			}
+			switch {
+			case errors.Is(err, repository.ErrLinkNotFound):
+				skipped.Add(1)
+				metrics.CacheWarmupLinks.WithLabelValues(metrics.WarmupSkipped).Inc()
+			case err != nil:
+				failed.Add(1)
+				metrics.CacheWarmupLinks.WithLabelValues(metrics.WarmupFailed).Inc()
+				w.logger.Debug("Не удалось загрузить ссылку в кэш", zap.String("key", key), zap.Error(err))
+			default:
+				loaded.Add(1)
+				metrics.CacheWarmupLinks.WithLabelValues(metrics.WarmupLoaded).Inc()
+			}
+
+			progressMu.Lock()
+			defer progressMu.Unlock()
+			if done := int(loaded.Load() + failed.Load() + skipped.Load()); done%reportEvery == 0 && done < len(links) {
+				w.logger.Info("Прогрев кэша",
+					zap.Int("done", done),
+					zap.Int("total", len(links)),
+				)
+			}
+			return nil
+		})
+	}
+	group.Wait()
+
+	result := &WarmupResult{
+		Total:      len(links),
+		Loaded:     int(loaded.Load()),
+		Failed:     int(failed.Load()),
+		Skipped:    int(skipped.Load()),
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	w.logger.Info("Прогрев кэша завершён",
+		zap.Int("total", result.Total),
+		zap.Int("loaded", result.Loaded),
+		zap.Int("failed", result.Failed),
+		zap.Int("skipped", result.Skipped),
+		zap.Duration("duration", time.Since(start)),
+	)
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,96 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/SergeiKhy/url-shortener/internal/repository"
+	"github.com/SergeiKhy/url-shortener/internal/service"
+	"github.com/SergeiKhy/url-shortener/internal/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCacheWarmer_Warmup проверяет, что прогрев загружает в кэш не больше TopN ссылок
+func TestCacheWarmer_Warmup(t *testing.T) {
+	linkRepo := mocks.NewMockLinkRepository()
+	cacheRepo := mocks.NewMockCacheRepository()
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, linkRepo.Create(ctx, &models.Link{
+			ShortCode:   fmt.Sprintf("warm%04d", i),
+			OriginalURL: "https://example.com",
+		}))
+	}
+
+	warmer := service.NewCacheWarmer(linkRepo, cacheRepo, service.CacheWarmerConfig{
+		TopN:        15,
+		Window:      time.Hour,
+		Concurrency: 4,
+	}, zap.NewNop())
+
+	result, err := warmer.Warmup(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 15, result.Total)
+	assert.Equal(t, 15, result.Loaded)
+	assert.Zero(t, result.Failed)
+
+	_, err = cacheRepo.Get(ctx, "warm0000")
+	assert.NoError(t, err)
+	_, err = cacheRepo.Get(ctx, "warm0019")
+	assert.Error(t, err, "ссылка вне TopN не должна попасть в кэш")
+}
+
+// deletingLinkRepository удаляет ссылку сразу после выбора популярных ссылок,
+// как параллельный DELETE между чтением и записью в кэш
+type deletingLinkRepository struct {
+	*mocks.MockLinkRepository
+	cacheRepo *mocks.MockCacheRepository
+	code      string
+}
+
+func (r *deletingLinkRepository) GetTopLinks(ctx context.Context, since time.Time, limit int) ([]*models.Link, error) {
+	links, err := r.MockLinkRepository.GetTopLinks(ctx, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Delete(ctx, "", r.code); err != nil {
+		return nil, err
+	}
+	return links, r.cacheRepo.Delete(ctx, r.code)
+}
+
+// TestCacheWarmer_ConcurrentDelete проверяет, что прогрев не возвращает в кэш ссылку,
+// удалённую после выбора популярных ссылок
+func TestCacheWarmer_ConcurrentDelete(t *testing.T) {
+	cacheRepo := mocks.NewMockCacheRepository()
+	linkRepo := &deletingLinkRepository{
+		MockLinkRepository: mocks.NewMockLinkRepository(),
+		cacheRepo:          cacheRepo,
+		code:               "gone0001",
+	}
+	ctx := context.Background()
+
+	for _, code := range []string{"kept0001", "gone0001"} {
+		require.NoError(t, linkRepo.Create(ctx, &models.Link{ShortCode: code, OriginalURL: "https://example.com"}))
+	}
+
+	warmer := service.NewCacheWarmer(linkRepo, cacheRepo, service.CacheWarmerConfig{}, zap.NewNop())
+
+	result, err := warmer.Warmup(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Total)
+	assert.Equal(t, 1, result.Loaded)
+	assert.Equal(t, 1, result.Skipped)
+
+	_, err = cacheRepo.Get(ctx, "kept0001")
+	assert.NoError(t, err)
+	_, err = cacheRepo.Get(ctx, "gone0001")
+	assert.ErrorIs(t, err, repository.ErrCacheMiss, "удалённая ссылка не должна вернуться в кэш")
+}
@@ -5,16 +5,20 @@ import (
 	"sync"
 	"time"
 
+	"github.com/SergeiKhy/url-shortener/internal/logging"
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
 	"github.com/SergeiKhy/url-shortener/internal/models"
 	"github.com/SergeiKhy/url-shortener/internal/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 // Константы worker pool
 const (
-	defaultWorkerCount   = 3  // Количество воркеров
+	defaultWorkerCount   = 3    // Количество воркеров
 	defaultChannelBuffer = 1000 // Размер буфера канала
-	maxRetries           = 3  // Максимальное количество попыток записи
+	maxRetries           = 3    // Максимальное количество попыток записи
 )
 
 // ClickProcessor интерфейс для асинхронного отслеживания кликов
@@ -22,8 +26,9 @@ type ClickProcessor interface {
 	Start()
 	Stop()
 	RecordClick(ctx context.Context, event *models.ClickEvent) error
-	GetStats(ctx context.Context, shortCode string) (*models.ClickStats, error)
-	GetDailyStats(ctx context.Context, shortCode string, days int) ([]models.DailyClickStats, error)
+	GetStats(ctx context.Context, domain, shortCode string) (*models.ClickStats, error)
+	GetDailyStats(ctx context.Context, domain, shortCode string, days int) ([]models.DailyClickStats, error)
+	GetChannelStats() ChannelStats
 }
 
 // clickProcessor реализация процессора кликов с использованием Worker Pool
@@ -99,10 +104,29 @@ func (p *clickProcessor) processClick(event *models.ClickEvent) {
 	ctx, cancel := context.WithTimeout(p.ctx, 5*time.Second)
 	defer cancel()
 
+	// Клик обрабатывается после завершения запроса, поэтому начинаем новый трейс
+	// со ссылкой на спан редиректа
+	ctx, span := tracer.Start(ctx, "ClickProcessor.processClick",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.Link{SpanContext: event.SpanContext}),
+		trace.WithAttributes(
+			attribute.String("link.domain", event.Domain),
+			attribute.String("link.code", event.ShortCode),
+		),
+	)
+	defer span.End()
+
+	logger := p.logger
+	if event.RequestID != "" {
+		logger = logger.With(zap.String("request_id", event.RequestID))
+	}
+
 	// Получаем ID ссылки по короткому коду
-	linkID, err := p.linkRepo.GetLinkIDByShortCode(ctx, event.ShortCode)
+	linkID, err := p.linkRepo.GetLinkIDByShortCode(ctx, event.Domain, event.ShortCode)
 	if err != nil {
-		p.logger.Warn("Не удалось получить ID ссылки для клика",
+		recordError(span, err)
+		logger.Warn("Не удалось получить ID ссылки для клика",
+			zap.String("domain", event.Domain),
 			zap.String("short_code", event.ShortCode),
 			zap.Error(err),
 		)
@@ -116,17 +140,19 @@ func (p *clickProcessor) processClick(event *models.ClickEvent) {
 		UserAgent: event.UserAgent,
 		Referer:   event.Referer,
 		Country:   event.Country,
+		Rule:      event.Rule,
+		Variant:   event.Variant,
 		ClickedAt: time.Now(),
 	}
 
 	// Retry логика для записи в БД
 	for i := 0; i < maxRetries; i++ {
-		if err := p.clickRepo.RecordClick(ctx, click); err == nil {
+		if err = p.clickRepo.RecordClick(ctx, click); err == nil {
 			return
 		}
 		// Логгируем попытку retry
 		if i < maxRetries-1 {
-			p.logger.Debug("Повторная попытка записи клика",
+			logger.Debug("Повторная попытка записи клика",
 				zap.String("short_code", event.ShortCode),
 				zap.Int("attempt", i+1),
 				zap.Error(err),
@@ -135,7 +161,8 @@ func (p *clickProcessor) processClick(event *models.ClickEvent) {
 		}
 	}
 
-	p.logger.Error("Не удалось записать клик после всех попыток",
+	recordError(span, err)
+	logger.Error("Не удалось записать клик после всех попыток",
 		zap.String("short_code", event.ShortCode),
 		zap.Error(err),
 	)
@@ -143,14 +170,22 @@ func (p *clickProcessor) processClick(event *models.ClickEvent) {
 
 // RecordClick отправляет событие клика в worker pool (неблокирующая операция)
 func (p *clickProcessor) RecordClick(ctx context.Context, event *models.ClickEvent) error {
+	// Сохраняем контекст трейса запроса, чтобы связать с ним асинхронную обработку
+	span := trace.SpanFromContext(ctx)
+	event.SpanContext = span.SpanContext()
+	event.RequestID = logging.RequestIDFromContext(ctx)
+
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	case p.clickChannel <- event:
+		span.AddEvent("click enqueued")
 		return nil
 	default:
 		// Канал заполнен, логируем предупреждение, но не блокируем запрос
-		p.logger.Warn("Буфер канала кликов заполнен, событие потеряно",
+		metrics.ClicksDropped.Inc()
+		span.AddEvent("click dropped: channel full")
+		logging.FromContext(ctx, p.logger).Warn("Буфер канала кликов заполнен, событие потеряно",
 			zap.String("short_code", event.ShortCode),
 		)
 		return nil // Не прерываем запрос, просто теряем статистику
@@ -158,13 +193,13 @@ func (p *clickProcessor) RecordClick(ctx context.Context, event *models.ClickEve
 }
 
 // GetStats получает статистику кликов для короткого кода
-func (p *clickProcessor) GetStats(ctx context.Context, shortCode string) (*models.ClickStats, error) {
-	return p.clickRepo.GetStats(ctx, shortCode)
+func (p *clickProcessor) GetStats(ctx context.Context, domain, shortCode string) (*models.ClickStats, error) {
+	return p.clickRepo.GetStats(ctx, domain, shortCode)
 }
 
 // GetDailyStats получает дневную статистику кликов
-func (p *clickProcessor) GetDailyStats(ctx context.Context, shortCode string, days int) ([]models.DailyClickStats, error) {
-	return p.clickRepo.GetDailyStats(ctx, shortCode, days)
+func (p *clickProcessor) GetDailyStats(ctx context.Context, domain, shortCode string, days int) ([]models.DailyClickStats, error) {
+	return p.clickRepo.GetDailyStats(ctx, domain, shortCode, days)
 }
 
 // GetChannelStats возвращает статистику канала для мониторинга
@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/SergeiKhy/url-shortener/internal/service"
+	"github.com/SergeiKhy/url-shortener/internal/service/mocks"
+)
+
+// TestClickProcessor_LinksTraceToRequest проверяет, что спан обработки клика ссылается на спан запроса
+func TestClickProcessor_LinksTraceToRequest(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider()) })
+
+	linkRepo := mocks.NewMockLinkRepository()
+	clickRepo := mocks.NewMockClickRepository()
+	logger, _ := zap.NewDevelopment()
+
+	ctx := context.Background()
+	require.NoError(t, linkRepo.Create(ctx, &models.Link{
+		ShortCode:   "traced",
+		OriginalURL: "https://example.com",
+		CreatedAt:   time.Now(),
+	}))
+
+	processor := service.NewClickProcessor(clickRepo, linkRepo, logger)
+	processor.Start()
+
+	// Имитируем запрос редиректа с активным спаном
+	reqCtx, reqSpan := provider.Tracer("test").Start(ctx, "GET /:code")
+	err := processor.RecordClick(reqCtx, &models.ClickEvent{ShortCode: "traced", IPAddress: "10.0.0.1"})
+	require.NoError(t, err)
+	reqSpan.End()
+
+	// Ждём, пока воркер запишет клик
+	assert.Eventually(t, func() bool {
+		stats, _ := clickRepo.GetStats(ctx, "", "traced")
+		return stats.TotalClicks == 1
+	}, time.Second, 10*time.Millisecond)
+	processor.Stop()
+
+	var clickSpan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "ClickProcessor.processClick" {
+			clickSpan = span
+		}
+	}
+	require.NotNil(t, clickSpan, "спан обработки клика должен быть записан")
+	require.Len(t, clickSpan.Links(), 1)
+	assert.Equal(t, reqSpan.SpanContext().TraceID(), clickSpan.Links()[0].SpanContext.TraceID())
+	assert.NotEqual(t, reqSpan.SpanContext().TraceID(), clickSpan.SpanContext().TraceID())
+}
+
+// TestClickProcessor_RecordsVariant проверяет запись варианта A/B теста и статистику по вариантам
+func TestClickProcessor_RecordsVariant(t *testing.T) {
+	linkRepo := mocks.NewMockLinkRepository()
+	clickRepo := mocks.NewMockClickRepository()
+
+	ctx := context.Background()
+	require.NoError(t, linkRepo.Create(ctx, &models.Link{
+		ShortCode:   "landing",
+		OriginalURL: "https://example.com",
+		CreatedAt:   time.Now(),
+	}))
+
+	processor := service.NewClickProcessor(clickRepo, linkRepo, zap.NewNop())
+	processor.Start()
+	for _, event := range []*models.ClickEvent{
+		{ShortCode: "landing", IPAddress: "10.0.0.1", Variant: "control"},
+		{ShortCode: "landing", IPAddress: "10.0.0.1", Variant: "control"},
+		{ShortCode: "landing", IPAddress: "10.0.0.2", Variant: "new"},
+		{ShortCode: "landing", IPAddress: "10.0.0.3"},
+	} {
+		require.NoError(t, processor.RecordClick(ctx, event))
+	}
+
+	var stats *models.ClickStats
+	assert.Eventually(t, func() bool {
+		stats, _ = clickRepo.GetStats(ctx, "", "landing")
+		return stats.TotalClicks == 4
+	}, time.Second, 10*time.Millisecond)
+	processor.Stop()
+
+	assert.Equal(t, []models.VariantClickStats{
+		{Variant: "control", TotalClicks: 2, UniqueClicks: 1},
+		{Variant: "new", TotalClicks: 1, UniqueClicks: 1},
+	}, stats.Variants)
+}
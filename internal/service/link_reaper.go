@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/SergeiKhy/url-shortener/internal/repository"
+	"go.uber.org/zap"
+)
+
+// Константы очистки истёкших ссылок по умолчанию
+const (
+	defaultReaperInterval    = 10 * time.Minute
+	defaultReaperGracePeriod = 24 * time.Hour
+	defaultReaperBatchSize   = 500
+	// reaperLockName имя блокировки: очистку выполняет только один инстанс
+	reaperLockName = "link-reaper"
+)
+
+// LinkReaperConfig конфигурация очистки истёкших ссылок
+type LinkReaperConfig struct {
+	Interval    time.Duration // Интервал запуска очистки
+	GracePeriod time.Duration // Сколько ссылка хранится после истечения
+	BatchSize   int           // Сколько ссылок удаляется одной транзакцией
+	Archive     bool          // Переносить ссылки в links_archive вместо удаления
+	ReuseCodes  bool          // Разрешить повторную выдачу кодов удалённых ссылок
+}
+
+// ReapResult итог очистки
+type ReapResult struct {
+	Purged  int  // Удалено ссылок
+	Skipped bool // Очистку выполняет другой инстанс
+}
+
+// LinkReaper периодически удаляет ссылки, истёкшие больше GracePeriod назад, и убирает их из кэша
+type LinkReaper interface {
+	Start()
+	Stop()
+	// Reap выполняет одну очистку
+	Reap(ctx context.Context) (*ReapResult, error)
+}
+
+// linkReaper реализация очистки истёкших ссылок
+type linkReaper struct {
+	linkRepo  repository.LinkRepository
+	cacheRepo repository.CacheRepository
+	locker    repository.Locker
+	config    LinkReaperConfig
+	logger    *zap.Logger
+	wg        sync.WaitGroup
+	cancel    context.CancelFunc
+}
+
+// NewLinkReaper создаёт новый экземпляр очистки истёкших ссылок
+func NewLinkReaper(
+	linkRepo repository.LinkRepository,
+	cacheRepo repository.CacheRepository,
+	locker repository.Locker,
+	config LinkReaperConfig,
+	logger *zap.Logger,
+) LinkReaper {
+	if config.Interval <= 0 {
+		config.Interval = defaultReaperInterval
+	}
+	if config.GracePeriod <= 0 {
+		config.GracePeriod = defaultReaperGracePeriod
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultReaperBatchSize
+	}
+
+	return &linkReaper{
+		linkRepo:  linkRepo,
+		cacheRepo: cacheRepo,
+		locker:    locker,
+		config:    config,
+		logger:    logger,
+	}
+}
+
+// Start запускает очистку каждые Interval
+func (r *linkReaper) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.Reap(ctx); err != nil && ctx.Err() == nil {
+					r.logger.Error("Ошибка очистки истёкших ссылок", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stop останавливает очистку, дожидаясь текущего пакета
+func (r *linkReaper) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *linkReaper) Reap(ctx context.Context) (*ReapResult, error) {
+	result := &ReapResult{}
+	start := time.Now()
+	before := start.Add(-r.config.GracePeriod)
+	opts := repository.PurgeOptions{
+		Archive:     r.config.Archive,
+		RetireCodes: !r.config.ReuseCodes,
+	}
+
+	locked, err := r.locker.TryLock(ctx, reaperLockName, func(ctx context.Context) error {
+		// Пакеты по BatchSize: короткие транзакции не блокируют таблицу надолго
+		for ctx.Err() == nil {
+			links, err := r.linkRepo.PurgeExpired(ctx, before, r.config.BatchSize, opts)
+			if err != nil {
+				return err
+			}
+			result.Purged += len(links)
+			metrics.ExpiredLinksPurged.Add(float64(len(links)))
+
+			// Удаление из кэша публикует инвалидацию локальных кэшей всех инстансов
+			for _, link := range links {
+				key := models.LinkKey(link.Domain, link.ShortCode)
+				if err := r.cacheRepo.Delete(ctx, key); err != nil {
+					r.logger.Debug("Не удалось удалить ссылку из кэша", zap.String("key", key), zap.Error(err))
+				}
+			}
+
+			if len(links) < r.config.BatchSize {
+				return nil
+			}
+		}
+		return ctx.Err()
+	})
+	if !locked && err == nil {
+		result.Skipped = true
+		r.logger.Debug("Очистку истёкших ссылок выполняет другой инстанс")
+		return result, nil
+	}
+
+	if result.Purged > 0 || err != nil {
+		r.logger.Info("Очистка истёкших ссылок",
+			zap.Int("purged", result.Purged),
+			zap.Bool("archive", r.config.Archive),
+			zap.Duration("duration", time.Since(start)),
+			zap.Error(err),
+		)
+	}
+	return result, err
+}
@@ -0,0 +1,91 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/SergeiKhy/url-shortener/internal/repository"
+	"github.com/SergeiKhy/url-shortener/internal/service"
+	"github.com/SergeiKhy/url-shortener/internal/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLinkReaper_Reap проверяет пакетное удаление истёкших ссылок и их удаление из кэша
+func TestLinkReaper_Reap(t *testing.T) {
+	linkRepo := mocks.NewMockLinkRepository()
+	cacheRepo := mocks.NewMockCacheRepository()
+	ctx := context.Background()
+
+	expired := time.Now().Add(-48 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		link := &models.Link{ShortCode: fmt.Sprintf("old%05d", i), OriginalURL: "https://example.com", ExpiresAt: &expired}
+		require.NoError(t, linkRepo.Create(ctx, link))
+		require.NoError(t, cacheRepo.Set(ctx, link.ShortCode, link, time.Hour))
+	}
+	require.NoError(t, linkRepo.Create(ctx, &models.Link{ShortCode: "recent01", OriginalURL: "https://example.com", ExpiresAt: &recent}))
+	require.NoError(t, linkRepo.Create(ctx, &models.Link{ShortCode: "forever1", OriginalURL: "https://example.com"}))
+
+	reaper := service.NewLinkReaper(linkRepo, cacheRepo, mocks.NewMockLocker(), service.LinkReaperConfig{
+		GracePeriod: 24 * time.Hour,
+		BatchSize:   2,
+	}, zap.NewNop())
+
+	result, err := reaper.Reap(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, result.Purged)
+	assert.False(t, result.Skipped)
+
+	_, err = cacheRepo.Get(ctx, "old00000")
+	assert.ErrorIs(t, err, repository.ErrCacheMiss)
+	_, err = linkRepo.GetByShortCode(ctx, "", "recent01")
+	assert.NoError(t, err, "ссылка в пределах grace period не удаляется")
+	_, err = linkRepo.GetByShortCode(ctx, "", "forever1")
+	assert.NoError(t, err)
+
+	// По умолчанию коды не выдаются повторно
+	err = linkRepo.Create(ctx, &models.Link{ShortCode: "old00000", OriginalURL: "https://example.com"})
+	assert.ErrorIs(t, err, repository.ErrCodeExists)
+}
+
+// TestLinkReaper_ReuseCodes проверяет освобождение кодов удалённых ссылок
+func TestLinkReaper_ReuseCodes(t *testing.T) {
+	linkRepo := mocks.NewMockLinkRepository()
+	ctx := context.Background()
+
+	expired := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, linkRepo.Create(ctx, &models.Link{ShortCode: "reuse001", OriginalURL: "https://example.com", ExpiresAt: &expired}))
+
+	reaper := service.NewLinkReaper(linkRepo, mocks.NewMockCacheRepository(), mocks.NewMockLocker(), service.LinkReaperConfig{
+		ReuseCodes: true,
+	}, zap.NewNop())
+
+	result, err := reaper.Reap(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Purged)
+
+	assert.NoError(t, linkRepo.Create(ctx, &models.Link{ShortCode: "reuse001", OriginalURL: "https://example.org"}))
+}
+
+// TestLinkReaper_Locked проверяет, что очистку выполняет только один инстанс
+func TestLinkReaper_Locked(t *testing.T) {
+	linkRepo := mocks.NewMockLinkRepository()
+	locker := mocks.NewMockLocker()
+	ctx := context.Background()
+
+	reaper := service.NewLinkReaper(linkRepo, mocks.NewMockCacheRepository(), locker, service.LinkReaperConfig{}, zap.NewNop())
+
+	_, err := locker.TryLock(ctx, "link-reaper", func(ctx context.Context) error {
+		result, err := reaper.Reap(ctx)
+		require.NoError(t, err)
+		assert.True(t, result.Skipped)
+		return nil
+	})
+	require.NoError(t, err)
+}
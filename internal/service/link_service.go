@@ -7,10 +7,19 @@ import (
 	"fmt"
 	"math/big"
 	"regexp"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/sync/singleflight"
 
+	"github.com/SergeiKhy/url-shortener/internal/logging"
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
 	"github.com/SergeiKhy/url-shortener/internal/models"
 	"github.com/SergeiKhy/url-shortener/internal/repository"
 )
@@ -20,16 +29,62 @@ var (
 	ErrInvalidURL  = errors.New("невалидный URL")
 	ErrInvalidCode = errors.New("невалидный кастомный код")
 	ErrSpamDomain  = errors.New("домен в чёрном списке")
+	// ErrInvalidRedirectType тип редиректа не из 301, 302, 307, 308
+	ErrInvalidRedirectType = errors.New("невалидный тип редиректа")
+	// ErrInvalidPassword пароль ссылки короче minPasswordLength или длиннее maxPasswordLength байт
+	ErrInvalidPassword = errors.New("невалидный пароль ссылки")
+	// ErrWrongPassword пароль не совпадает с паролем ссылки
+	ErrWrongPassword = errors.New("неверный пароль ссылки")
+	// ErrInvalidMaxClicks отрицательный лимит переходов
+	ErrInvalidMaxClicks = errors.New("невалидный лимит переходов")
+	// ErrLinkExhausted лимит переходов по ссылке исчерпан
+	ErrLinkExhausted = errors.New("лимит переходов по ссылке исчерпан")
+	// ErrInvalidSchedule время активации или истечения в прошлом, не по порядку
+	// или срок задан одновременно в минутах и абсолютным временем
+	ErrInvalidSchedule = errors.New("невалидное время действия ссылки")
+	// ErrLinkNotActive время активации ссылки ещё не наступило
+	ErrLinkNotActive = errors.New("ссылка ещё не активна")
+	// ErrLinkExpired срок действия ссылки истёк, но очистка её ещё не удалила
+	ErrLinkExpired = errors.New("срок действия ссылки истёк")
+	// ErrInvalidFallbackURL адрес для переходов после истечения не прошёл проверку URL
+	ErrInvalidFallbackURL = errors.New("невалидный fallback URL")
+	// ErrInvalidGeoRules код страны не из двух латинских букв, повторяется или адрес правила невалиден
+	ErrInvalidGeoRules = errors.New("невалидные правила геотаргетинга")
+	// ErrInvalidDeviceRules неизвестная платформа, невалидный адрес правила или
+	// deep link с недопустимой схемой
+	ErrInvalidDeviceRules = errors.New("невалидные правила по устройству")
+	// ErrInvalidVariants вариантов A/B теста меньше двух или больше maxVariants, имя
+	// невалидно или повторяется, адрес невалиден или вес вне 1..maxVariantWeight
+	ErrInvalidVariants = errors.New("невалидные варианты A/B теста")
+	// ErrInvalidTitle заголовок ссылки длиннее maxTitleLength символов
+	ErrInvalidTitle = errors.New("слишком длинный заголовок ссылки")
 )
 
 // Константы сервиса
 const (
 	defaultTTL = 24 * time.Hour
-	maxTTL     = 30 * 24 * time.Hour
-	codeLength = 8
-	charset    = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// negativeTTL время жизни записи о несуществующем коде: короткое, чтобы
+	// не держать устаревшее «нет такой ссылки», если код всё же создадут
+	negativeTTL = time.Minute
+	maxTTL      = 30 * 24 * time.Hour
+	codeLength  = 8
+	// Ограничения пароля ссылки: bcrypt учитывает только первые 72 байта
+	minPasswordLength = 4
+	maxPasswordLength = 72
+	// maxTitleLength максимальная длина заголовка ссылки в символах
+	maxTitleLength = 200
+	// maxGeoRules правил геотаргетинга у ссылки не больше, чем стран в ISO 3166-1
+	maxGeoRules = 250
+	// Ограничения A/B теста: вес задаёт долю трафика варианта, 0 (не задан) считается 1
+	maxVariants      = 10
+	maxVariantWeight = 1000
+	charset          = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 )
 
+// blockedDeepLinkSchemes схемы, которые браузер выполняет или открывает на месте
+// страницы, а не передаёт приложению
+var blockedDeepLinkSchemes = []string{"javascript", "data", "vbscript", "file", "blob", "about"}
+
 // Чёрный список доменов (можно вынести в конфиг или БД)
 var blacklistedDomains = []string{
 	"malware.com",
@@ -40,36 +95,111 @@ var blacklistedDomains = []string{
 // LinkService интерфейс сервиса ссылок
 type LinkService interface {
 	CreateLink(ctx context.Context, input *models.CreateLinkInput) (*models.Link, error)
-	GetLink(ctx context.Context, code string) (*models.Link, error)
-	DeleteLink(ctx context.Context, code string) error
+	// GetLink и DeleteLink принимают домен ссылки: пустой — домен по умолчанию.
+	// Для истёкшей ссылки GetLink возвращает её вместе с ErrLinkExpired, чтобы
+	// редирект мог перейти на FallbackURL.
+	GetLink(ctx context.Context, domain, code string) (*models.Link, error)
+	// UpdateLink меняет заданные поля ссылки
+	UpdateLink(ctx context.Context, domain, code string, input *models.UpdateLinkInput) (*models.Link, error)
+	DeleteLink(ctx context.Context, domain, code string) error
+	// CheckPassword сверяет пароль с паролем ссылки, ErrWrongPassword при несовпадении
+	CheckPassword(ctx context.Context, link *models.Link, password string) error
+	// ConsumeClick засчитывает переход по ссылке с лимитом, ErrLinkExhausted после исчерпания
+	ConsumeClick(ctx context.Context, link *models.Link) error
+	// CheckClicksLeft возвращает ErrLinkExhausted, если лимит переходов исчерпан, не засчитывая переход
+	CheckClicksLeft(ctx context.Context, link *models.Link) error
+}
+
+// LinkServiceConfig настройки сервиса ссылок
+type LinkServiceConfig struct {
+	// DefaultRedirectType тип редиректа новых ссылок, если он не указан при создании
+	DefaultRedirectType int
 }
 
 // linkService реализация сервиса ссылок
 type linkService struct {
 	linkRepo  repository.LinkRepository
 	cacheRepo repository.CacheRepository
+	config    LinkServiceConfig
 	logger    *zap.Logger
+	// lookups объединяет параллельные обращения к БД за одним кодом
+	lookups singleflight.Group
 }
 
 // NewLinkService создаёт новый экземпляр сервиса
-func NewLinkService(linkRepo repository.LinkRepository, cacheRepo repository.CacheRepository, logger *zap.Logger) LinkService {
+func NewLinkService(linkRepo repository.LinkRepository, cacheRepo repository.CacheRepository, config LinkServiceConfig, logger *zap.Logger) LinkService {
+	if config.DefaultRedirectType == 0 {
+		config.DefaultRedirectType = models.DefaultRedirectType
+	}
 	return &linkService{
 		linkRepo:  linkRepo,
 		cacheRepo: cacheRepo,
+		config:    config,
 		logger:    logger,
 	}
 }
 
 // CreateLink создаёт новую короткую ссылку
 func (s *linkService) CreateLink(ctx context.Context, input *models.CreateLinkInput) (*models.Link, error) {
+	ctx, span := tracer.Start(ctx, "LinkService.CreateLink")
+	defer span.End()
+
 	// Валидация URL
 	if err := s.validateURL(input.OriginalURL); err != nil {
-		return nil, err
+		return nil, recordError(span, err)
 	}
 
 	// Проверка на спам-домены
 	if err := s.checkSpamDomain(input.OriginalURL); err != nil {
-		return nil, err
+		return nil, recordError(span, err)
+	}
+
+	// Тип редиректа сохраняется в ссылке: смена значения по умолчанию не меняет
+	// поведение уже выданных постоянных ссылок
+	redirectType := input.RedirectType
+	if redirectType == 0 {
+		redirectType = s.config.DefaultRedirectType
+	}
+	if !models.ValidRedirectType(redirectType) {
+		return nil, recordError(span, ErrInvalidRedirectType)
+	}
+
+	// Расчёт срока действия
+	startsAt, expiresAt, err := linkSchedule(input, time.Now())
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+
+	if input.MaxClicks < 0 {
+		return nil, recordError(span, ErrInvalidMaxClicks)
+	}
+
+	if err := s.validateFallbackURL(input.FallbackURL); err != nil {
+		return nil, recordError(span, err)
+	}
+	if utf8.RuneCountInString(input.Title) > maxTitleLength {
+		return nil, recordError(span, ErrInvalidTitle)
+	}
+	geoRules, err := s.normalizeGeoRules(input.GeoRules)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+	deviceRules, err := s.normalizeDeviceRules(input.DeviceRules)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+	variants, err := s.normalizeVariants(input.Variants)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+
+	var passwordHash string
+	if input.Password != "" {
+		hash, err := hashPassword(input.Password)
+		if err != nil {
+			return nil, recordError(span, err)
+		}
+		passwordHash = hash
 	}
 
 	// Генерация короткого кода
@@ -77,89 +207,354 @@ func (s *linkService) CreateLink(ctx context.Context, input *models.CreateLinkIn
 	if shortCode == nil || *shortCode == "" {
 		code, err := s.generateShortCode()
 		if err != nil {
-			return nil, fmt.Errorf("failed to generate code: %w", err)
+			return nil, recordError(span, fmt.Errorf("failed to generate code: %w", err))
 		}
 		shortCode = &code
 	} else {
 		// Валидация кастомного кода
 		if err := s.validateCustomCode(*shortCode); err != nil {
-			return nil, ErrInvalidCode
+			return nil, recordError(span, ErrInvalidCode)
 		}
 	}
-
-	// Расчёт TTL
-	var expiresAt *time.Time
-	if input.ExpiresIn != nil && *input.ExpiresIn > 0 {
-		ttl := time.Duration(*input.ExpiresIn) * time.Minute
-		if ttl > maxTTL {
-			ttl = maxTTL
-		}
-		t := time.Now().Add(ttl)
-		expiresAt = &t
-	}
+	span.SetAttributes(attribute.String("link.domain", input.Domain), attribute.String("link.code", *shortCode))
 
 	// Создание ссылки
 	link := &models.Link{
-		ShortCode:   *shortCode,
-		OriginalURL: input.OriginalURL,
-		ExpiresAt:   expiresAt,
-		CreatedAt:   time.Now(),
+		Domain:       input.Domain,
+		ShortCode:    *shortCode,
+		OriginalURL:  input.OriginalURL,
+		Title:        input.Title,
+		Preview:      input.Preview,
+		RedirectType: redirectType,
+		PasswordHash: passwordHash,
+		MaxClicks:    input.MaxClicks,
+		GeoRules:     geoRules,
+		DeviceRules:  deviceRules,
+		Variants:     variants,
+		FallbackURL:  input.FallbackURL,
+		StartsAt:     startsAt,
+		ExpiresAt:    expiresAt,
+		CreatedAt:    time.Now(),
 	}
 
 	if err := s.linkRepo.Create(ctx, link); err != nil {
 		if errors.Is(err, repository.ErrCodeExists) {
 			// Retry с новым кодом
 			if input.CustomCode == nil || *input.CustomCode == "" {
+				span.AddEvent("short code collision, retrying")
 				return s.CreateLink(ctx, input)
 			}
 		}
-		return nil, err
-	}
-
-	// Кэширование
-	ttl := defaultTTL
-	if expiresAt != nil {
-		ttl = time.Until(*expiresAt)
+		return nil, recordError(span, err)
 	}
 
-	if err := s.cacheRepo.Set(ctx, link.ShortCode, link, ttl); err != nil {
-		s.logger.Warn("Failed to cache link", zap.String("code", link.ShortCode), zap.Error(err))
+	// Кэширование. Set перезаписывает negative-запись, если код ранее запрашивали
+	key := models.LinkKey(link.Domain, link.ShortCode)
+	if err := s.cacheRepo.Set(ctx, key, link, cacheTTL(link)); err != nil {
+		logging.FromContext(ctx, s.logger).Warn("Failed to cache link", zap.String("key", key), zap.Error(err))
 	}
 
 	return link, nil
 }
 
 // GetLink получает ссылку по короткому коду (сначала из кэша, затем из БД)
-func (s *linkService) GetLink(ctx context.Context, code string) (*models.Link, error) {
+func (s *linkService) GetLink(ctx context.Context, domain, code string) (*models.Link, error) {
+	ctx, span := tracer.Start(ctx, "LinkService.GetLink", trace.WithAttributes(
+		attribute.String("link.domain", domain),
+		attribute.String("link.code", code),
+	))
+	defer span.End()
+
 	// Проверка кэша
-	link, err := s.cacheRepo.Get(ctx, code)
+	key := models.LinkKey(domain, code)
+	link, err := s.cacheRepo.Get(ctx, key)
 	if err == nil {
-		return link, nil
+		metrics.RedirectCacheRequests.WithLabelValues(metrics.ResultHit).Inc()
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		return checkSchedule(span, link)
+	}
+	if errors.Is(err, repository.ErrLinkNotFound) {
+		// Закэшированное отсутствие ссылки: в БД не идём
+		metrics.RedirectCacheRequests.WithLabelValues(metrics.ResultNegativeHit).Inc()
+		span.SetAttributes(attribute.Bool("cache.hit", true), attribute.Bool("cache.negative", true))
+		return nil, err
+	}
+	metrics.RedirectCacheRequests.WithLabelValues(metrics.ResultMiss).Inc()
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+
+	// Параллельные промахи по одному коду выполняют один запрос в БД. Запрос не
+	// отменяется вместе с первым клиентом, так как его результат ждут остальные.
+	result, err, shared := s.lookups.Do(key, func() (interface{}, error) {
+		return s.loadLink(context.WithoutCancel(ctx), domain, code)
+	})
+	if shared {
+		metrics.LinkLookupsCoalesced.Inc()
+		span.SetAttributes(attribute.Bool("lookup.shared", true))
+	}
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+
+	// Каждый вызывающий получает свою копию
+	linkCopy := *result.(*models.Link)
+	return checkSchedule(span, &linkCopy)
+}
+
+// checkSchedule не отдаёт ссылку до времени активации и помечает истёкшую ссылку.
+// Ссылка кэшируется и до активации, а истёкшая читается из БД до её удаления очисткой,
+// поэтому проверка выполняется после чтения из кэша или БД, а не в запросе к БД.
+func checkSchedule(span trace.Span, link *models.Link) (*models.Link, error) {
+	now := time.Now()
+	if link.NotStarted(now) {
+		span.SetAttributes(attribute.Bool("link.not_started", true))
+		return nil, recordError(span, ErrLinkNotActive)
+	}
+	if link.Expired(now) {
+		span.SetAttributes(attribute.Bool("link.expired", true))
+		return link, recordError(span, ErrLinkExpired)
+	}
+	return link, nil
+}
+
+// linkSchedule время активации и истечения новой ссылки. Срок в минутах ограничен maxTTL,
+// абсолютное время истечения должно быть в будущем и позже времени активации.
+func linkSchedule(input *models.CreateLinkInput, now time.Time) (startsAt, expiresAt *time.Time, err error) {
+	if input.ExpiresIn != nil && input.ExpiresAt != nil {
+		return nil, nil, ErrInvalidSchedule
+	}
+
+	// Время хранится в БД без часового пояса — приводим к локальному, как time.Now()
+	if input.StartsAt != nil {
+		t := input.StartsAt.Local()
+		startsAt = &t
+	}
+
+	switch {
+	case input.ExpiresIn != nil && *input.ExpiresIn > 0:
+		ttl := time.Duration(*input.ExpiresIn) * time.Minute
+		if ttl > maxTTL {
+			ttl = maxTTL
+		}
+		t := now.Add(ttl)
+		expiresAt = &t
+	case input.ExpiresAt != nil:
+		if !input.ExpiresAt.After(now) {
+			return nil, nil, ErrInvalidSchedule
+		}
+		t := input.ExpiresAt.Local()
+		expiresAt = &t
+	}
+
+	if startsAt != nil && expiresAt != nil && !startsAt.Before(*expiresAt) {
+		return nil, nil, ErrInvalidSchedule
 	}
+	return startsAt, expiresAt, nil
+}
 
-	// Запрос из БД
-	link, err = s.linkRepo.GetByShortCode(ctx, code)
+// loadLink читает ссылку из БД и кэширует результат, в том числе отсутствие ссылки
+func (s *linkService) loadLink(ctx context.Context, domain, code string) (*models.Link, error) {
+	key := models.LinkKey(domain, code)
+	link, err := s.linkRepo.GetByShortCode(ctx, domain, code)
+	if errors.Is(err, repository.ErrLinkNotFound) {
+		if cacheErr := s.cacheRepo.SetNotFound(ctx, key, negativeTTL); cacheErr != nil {
+			logging.FromContext(ctx, s.logger).Debug("Failed to cache missing link", zap.String("key", key), zap.Error(cacheErr))
+		}
+		return nil, err
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// Кэширование результата
-	ttl := defaultTTL
-	if link.ExpiresAt != nil {
-		ttl = time.Until(*link.ExpiresAt)
+	if err := s.cacheRepo.Set(ctx, key, link, cacheTTL(link)); err != nil {
+		logging.FromContext(ctx, s.logger).Debug("Failed to cache link", zap.String("key", key), zap.Error(err))
+	}
+
+	return link, nil
+}
+
+// UpdateLink меняет заданные поля ссылки и сбрасывает её кэш
+func (s *linkService) UpdateLink(ctx context.Context, domain, code string, input *models.UpdateLinkInput) (*models.Link, error) {
+	ctx, span := tracer.Start(ctx, "LinkService.UpdateLink", trace.WithAttributes(
+		attribute.String("link.domain", domain),
+		attribute.String("link.code", code),
+	))
+	defer span.End()
+
+	if input.RedirectType != nil && !models.ValidRedirectType(*input.RedirectType) {
+		return nil, recordError(span, ErrInvalidRedirectType)
+	}
+	if input.MaxClicks != nil && *input.MaxClicks < 0 {
+		return nil, recordError(span, ErrInvalidMaxClicks)
+	}
+	if input.FallbackURL != nil {
+		if err := s.validateFallbackURL(*input.FallbackURL); err != nil {
+			return nil, recordError(span, err)
+		}
+	}
+	if input.Title != nil && utf8.RuneCountInString(*input.Title) > maxTitleLength {
+		return nil, recordError(span, ErrInvalidTitle)
+	}
+	if input.GeoRules != nil {
+		geoRules, err := s.normalizeGeoRules(input.GeoRules)
+		if err != nil {
+			return nil, recordError(span, err)
+		}
+		input.GeoRules = geoRules
+	}
+	if input.DeviceRules != nil {
+		deviceRules, err := s.normalizeDeviceRules(input.DeviceRules)
+		if err != nil {
+			return nil, recordError(span, err)
+		}
+		input.DeviceRules = deviceRules
+	}
+	if input.Variants != nil {
+		variants, err := s.normalizeVariants(input.Variants)
+		if err != nil {
+			return nil, recordError(span, err)
+		}
+		// Пустой список, а не nil: репозиторий удаляет варианты
+		input.Variants = append([]models.Variant{}, variants...)
+	}
+	if input.Password != nil {
+		// Пустой пароль снимает защиту
+		var passwordHash string
+		if *input.Password != "" {
+			hash, err := hashPassword(*input.Password)
+			if err != nil {
+				return nil, recordError(span, err)
+			}
+			passwordHash = hash
+		}
+		input.PasswordHash = &passwordHash
+	}
+
+	link, err := s.linkRepo.Update(ctx, domain, code, input)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+
+	// Следующий редирект загрузит ссылку из БД; удаление из локального кэша
+	// рассылается остальным инстансам
+	key := models.LinkKey(domain, code)
+	if err := s.cacheRepo.Delete(ctx, key); err != nil {
+		logging.FromContext(ctx, s.logger).Warn("Failed to invalidate cached link", zap.String("key", key), zap.Error(err))
 	}
-	s.cacheRepo.Set(ctx, code, link, ttl)
 
 	return link, nil
 }
 
 // DeleteLink удаляет ссылку по короткому коду
-func (s *linkService) DeleteLink(ctx context.Context, code string) error {
+func (s *linkService) DeleteLink(ctx context.Context, domain, code string) error {
+	ctx, span := tracer.Start(ctx, "LinkService.DeleteLink", trace.WithAttributes(
+		attribute.String("link.domain", domain),
+		attribute.String("link.code", code),
+	))
+	defer span.End()
+
 	// Удаляем кэш
-	s.cacheRepo.Delete(ctx, code)
+	s.cacheRepo.Delete(ctx, models.LinkKey(domain, code))
 
 	// Удаляем из БД
-	return s.linkRepo.Delete(ctx, code)
+	return recordError(span, s.linkRepo.Delete(ctx, domain, code))
+}
+
+// CheckPassword сверяет пароль с bcrypt хэшем ссылки
+func (s *linkService) CheckPassword(ctx context.Context, link *models.Link, password string) error {
+	_, span := tracer.Start(ctx, "LinkService.CheckPassword", trace.WithAttributes(
+		attribute.String("link.domain", link.Domain),
+		attribute.String("link.code", link.ShortCode),
+	))
+	defer span.End()
+
+	if !link.HasPassword() {
+		return nil
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)); err != nil {
+		// Неверный пароль — штатная ситуация, спан не помечается ошибкой
+		span.SetAttributes(attribute.Bool("password.valid", false))
+		return ErrWrongPassword
+	}
+	span.SetAttributes(attribute.Bool("password.valid", true))
+	return nil
+}
+
+// ConsumeClick засчитывает переход условным UPDATE в PostgreSQL: в отличие от счётчика
+// в Redis он работает и без кэша, а лимит не теряется при вытеснении ключа
+func (s *linkService) ConsumeClick(ctx context.Context, link *models.Link) error {
+	if !link.ClickLimited() {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "LinkService.ConsumeClick", trace.WithAttributes(
+		attribute.String("link.domain", link.Domain),
+		attribute.String("link.code", link.ShortCode),
+		attribute.Int("link.max_clicks", link.MaxClicks),
+	))
+	defer span.End()
+
+	ok, err := s.linkRepo.ConsumeClick(ctx, link.ID)
+	if err != nil {
+		return recordError(span, err)
+	}
+	if !ok {
+		metrics.ExhaustedLinkRedirects.Inc()
+		span.SetAttributes(attribute.Bool("link.exhausted", true))
+		return ErrLinkExhausted
+	}
+	return nil
+}
+
+// CheckClicksLeft проверяет лимит перед страницей предпросмотра: исчерпанная ссылка
+// не должна показываться, хотя переход засчитывается только после «Continue»
+func (s *linkService) CheckClicksLeft(ctx context.Context, link *models.Link) error {
+	if !link.ClickLimited() {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "LinkService.CheckClicksLeft", trace.WithAttributes(
+		attribute.String("link.domain", link.Domain),
+		attribute.String("link.code", link.ShortCode),
+		attribute.Int("link.max_clicks", link.MaxClicks),
+	))
+	defer span.End()
+
+	ok, err := s.linkRepo.ClicksLeft(ctx, link.ID)
+	if err != nil {
+		return recordError(span, err)
+	}
+	if !ok {
+		metrics.ExhaustedLinkRedirects.Inc()
+		span.SetAttributes(attribute.Bool("link.exhausted", true))
+		return ErrLinkExhausted
+	}
+	return nil
+}
+
+// hashPassword проверяет длину пароля ссылки и возвращает его bcrypt хэш
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// cacheTTL время жизни ссылки в кэше: не дольше срока действия самой ссылки.
+// Истёкшая ссылка кэшируется ненадолго, как отсутствующая: нулевой или отрицательный
+// TTL сохранил бы ключ в Redis без срока.
+func cacheTTL(link *models.Link) time.Duration {
+	if link.ExpiresAt != nil {
+		if ttl := time.Until(*link.ExpiresAt); ttl > 0 {
+			return ttl
+		}
+		return negativeTTL
+	}
+	return defaultTTL
 }
 
 // generateShortCode генерирует случайный короткий код длиной 8 символов
@@ -186,6 +581,111 @@ func (s *linkService) validateURL(url string) error {
 	return nil
 }
 
+// validateFallbackURL проверяет адрес для переходов после истечения так же, как
+// оригинальный URL. Пустой адрес допустим: истёкшая ссылка отвечает 410.
+func (s *linkService) validateFallbackURL(fallbackURL string) error {
+	if fallbackURL == "" {
+		return nil
+	}
+	if s.validateURL(fallbackURL) != nil || s.checkSpamDomain(fallbackURL) != nil {
+		return ErrInvalidFallbackURL
+	}
+	return nil
+}
+
+// normalizeGeoRules приводит коды стран к верхнему регистру, как их возвращает GeoIP,
+// и проверяет адреса правил так же, как оригинальный URL
+func (s *linkService) normalizeGeoRules(rules models.GeoRules) (models.GeoRules, error) {
+	if rules == nil {
+		return nil, nil
+	}
+	if len(rules) > maxGeoRules {
+		return nil, ErrInvalidGeoRules
+	}
+	normalized := make(models.GeoRules, len(rules))
+	for country, destination := range rules {
+		country = strings.ToUpper(country)
+		if matched, _ := regexp.MatchString(`^[A-Z]{2}$`, country); !matched {
+			return nil, ErrInvalidGeoRules
+		}
+		if _, exists := normalized[country]; exists {
+			return nil, ErrInvalidGeoRules
+		}
+		if s.validateURL(destination) != nil || s.checkSpamDomain(destination) != nil {
+			return nil, ErrInvalidGeoRules
+		}
+		normalized[country] = destination
+	}
+	return normalized, nil
+}
+
+// normalizeDeviceRules приводит платформы к нижнему регистру и проверяет адреса правил
+// так же, как оригинальный URL. Deep link может иметь любую схему приложения, кроме
+// выполняемых браузером: страница открытия приложения вставляет его в ссылку.
+func (s *linkService) normalizeDeviceRules(rules models.DeviceRules) (models.DeviceRules, error) {
+	if rules == nil {
+		return nil, nil
+	}
+	normalized := make(models.DeviceRules, len(rules))
+	for platform, rule := range rules {
+		platform = strings.ToLower(platform)
+		if !models.ValidPlatform(platform) {
+			return nil, ErrInvalidDeviceRules
+		}
+		if _, exists := normalized[platform]; exists {
+			return nil, ErrInvalidDeviceRules
+		}
+		if s.validateURL(rule.URL) != nil || s.checkSpamDomain(rule.URL) != nil {
+			return nil, ErrInvalidDeviceRules
+		}
+		if rule.DeepLink != "" && !validDeepLink(rule.DeepLink) {
+			return nil, ErrInvalidDeviceRules
+		}
+		normalized[platform] = rule
+	}
+	return normalized, nil
+}
+
+// normalizeVariants проверяет варианты A/B теста и подставляет вес 1 вместо незаданного.
+// Имена вариантов попадают в статистику кликов, поэтому ограничены как короткие коды.
+func (s *linkService) normalizeVariants(variants []models.Variant) ([]models.Variant, error) {
+	if len(variants) == 0 {
+		return nil, nil
+	}
+	if len(variants) < 2 || len(variants) > maxVariants {
+		return nil, ErrInvalidVariants
+	}
+	normalized := make([]models.Variant, 0, len(variants))
+	names := make(map[string]bool, len(variants))
+	for _, variant := range variants {
+		if matched, _ := regexp.MatchString(`^[a-zA-Z0-9_-]{1,32}$`, variant.Name); !matched || names[variant.Name] {
+			return nil, ErrInvalidVariants
+		}
+		names[variant.Name] = true
+		if s.validateURL(variant.URL) != nil || s.checkSpamDomain(variant.URL) != nil {
+			return nil, ErrInvalidVariants
+		}
+		if variant.Weight == 0 {
+			variant.Weight = 1
+		}
+		if variant.Weight < 0 || variant.Weight > maxVariantWeight {
+			return nil, ErrInvalidVariants
+		}
+		normalized = append(normalized, variant)
+	}
+	return normalized, nil
+}
+
+// validDeepLink проверяет, что ссылка в приложение начинается со схемы и не содержит пробелов
+func validDeepLink(deepLink string) bool {
+	matched, _ := regexp.MatchString(`^[a-zA-Z][a-zA-Z0-9+.-]*:\S+$`, deepLink)
+	if !matched {
+		return false
+	}
+	scheme, _, _ := strings.Cut(deepLink, ":")
+	return !slices.Contains(blockedDeepLinkSchemes, strings.ToLower(scheme))
+}
+
 // validateCustomCode проверяет формат кастомного кода (4-12 символов, буквы и цифры)
 func (s *linkService) validateCustomCode(code string) error {
 	if len(code) < 4 || len(code) > 12 {
@@ -3,12 +3,16 @@ package service_test
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/SergeiKhy/url-shortener/internal/models"
+	"github.com/SergeiKhy/url-shortener/internal/repository"
 	"github.com/SergeiKhy/url-shortener/internal/service"
 	"github.com/SergeiKhy/url-shortener/internal/service/mocks"
 	"github.com/stretchr/testify/assert"
@@ -20,7 +24,7 @@ func setupTestService() (service.LinkService, *mocks.MockLinkRepository, *mocks.
 	linkRepo := mocks.NewMockLinkRepository()
 	cacheRepo := mocks.NewMockCacheRepository()
 	logger, _ := zap.NewDevelopment()
-	linkService := service.NewLinkService(linkRepo, cacheRepo, logger)
+	linkService := service.NewLinkService(linkRepo, cacheRepo, service.LinkServiceConfig{}, logger)
 	return linkService, linkRepo, cacheRepo
 }
 
@@ -149,7 +153,7 @@ func TestLinkService_GetLink_FromCache(t *testing.T) {
 	assert.Equal(t, createdLink.ShortCode, cachedLink.ShortCode)
 
 	// Получаем ссылку (должна вернуться из кэша)
-	retrievedLink, err := linkService.GetLink(ctx, createdLink.ShortCode)
+	retrievedLink, err := linkService.GetLink(ctx, "", createdLink.ShortCode)
 	require.NoError(t, err)
 	assert.Equal(t, createdLink.ShortCode, retrievedLink.ShortCode)
 }
@@ -159,12 +163,449 @@ func TestLinkService_GetLink_NotFound(t *testing.T) {
 	linkService, _, _ := setupTestService()
 
 	ctx := context.Background()
-	link, err := linkService.GetLink(ctx, "nonexistent")
+	link, err := linkService.GetLink(ctx, "", "nonexistent")
 
 	assert.Error(t, err)
 	assert.Nil(t, link)
 }
 
+// TestLinkService_Domains проверяет, что один код независимо существует на разных доменах
+func TestLinkService_Domains(t *testing.T) {
+	linkService, _, cacheRepo := setupTestService()
+	ctx := context.Background()
+
+	code := "promo"
+	for domain, target := range map[string]string{
+		"":               "https://example.com/default",
+		"go.example.com": "https://example.com/branded",
+	} {
+		link, err := linkService.CreateLink(ctx, &models.CreateLinkInput{
+			OriginalURL: target,
+			CustomCode:  &code,
+			Domain:      domain,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, domain, link.Domain)
+	}
+
+	link, err := linkService.GetLink(ctx, "go.example.com", code)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/branded", link.OriginalURL)
+
+	link, err = linkService.GetLink(ctx, "", code)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/default", link.OriginalURL)
+
+	// Удаление на одном домене не затрагивает другой
+	require.NoError(t, linkService.DeleteLink(ctx, "go.example.com", code))
+	_, err = cacheRepo.Get(ctx, "go.example.com/"+code)
+	assert.ErrorIs(t, err, repository.ErrCacheMiss)
+	_, err = linkService.GetLink(ctx, "", code)
+	assert.NoError(t, err)
+}
+
+// TestLinkService_RedirectType проверяет тип редиректа по умолчанию и заданный при создании
+func TestLinkService_RedirectType(t *testing.T) {
+	linkService, _, _ := setupTestService()
+	ctx := context.Background()
+
+	link, err := linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com/default"})
+	require.NoError(t, err)
+	assert.Equal(t, models.RedirectTemporary, link.RedirectType)
+
+	link, err = linkService.CreateLink(ctx, &models.CreateLinkInput{
+		OriginalURL:  "https://example.com/permanent",
+		RedirectType: models.RedirectMovedPermanently,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.RedirectMovedPermanently, link.RedirectType)
+
+	_, err = linkService.CreateLink(ctx, &models.CreateLinkInput{
+		OriginalURL:  "https://example.com/invalid",
+		RedirectType: 200,
+	})
+	assert.ErrorIs(t, err, service.ErrInvalidRedirectType)
+
+	// Значение по умолчанию из конфигурации сервиса
+	configured := service.NewLinkService(mocks.NewMockLinkRepository(), mocks.NewMockCacheRepository(),
+		service.LinkServiceConfig{DefaultRedirectType: models.RedirectPermanent}, zap.NewNop())
+	link, err = configured.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com/seo"})
+	require.NoError(t, err)
+	assert.Equal(t, models.RedirectPermanent, link.RedirectType)
+}
+
+// TestLinkService_UpdateLink проверяет изменение типа редиректа и сброс кэша
+func TestLinkService_UpdateLink(t *testing.T) {
+	linkService, _, cacheRepo := setupTestService()
+	ctx := context.Background()
+
+	created, err := linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com/update"})
+	require.NoError(t, err)
+
+	redirectType := models.RedirectPermanent
+	updated, err := linkService.UpdateLink(ctx, "", created.ShortCode, &models.UpdateLinkInput{RedirectType: &redirectType})
+	require.NoError(t, err)
+	assert.Equal(t, models.RedirectPermanent, updated.RedirectType)
+	assert.Equal(t, created.OriginalURL, updated.OriginalURL)
+
+	// Закэшированная при создании ссылка сброшена, редирект получает новый тип из БД
+	_, err = cacheRepo.Get(ctx, created.ShortCode)
+	assert.ErrorIs(t, err, repository.ErrCacheMiss)
+	link, err := linkService.GetLink(ctx, "", created.ShortCode)
+	require.NoError(t, err)
+	assert.Equal(t, models.RedirectPermanent, link.RedirectType)
+
+	invalid := 303
+	_, err = linkService.UpdateLink(ctx, "", created.ShortCode, &models.UpdateLinkInput{RedirectType: &invalid})
+	assert.ErrorIs(t, err, service.ErrInvalidRedirectType)
+
+	_, err = linkService.UpdateLink(ctx, "", "missing", &models.UpdateLinkInput{RedirectType: &redirectType})
+	assert.ErrorIs(t, err, repository.ErrLinkNotFound)
+
+	// Заголовок предпросмотра ограничен по символам, а не байтам
+	title, preview := strings.Repeat("ж", 200), true
+	updated, err = linkService.UpdateLink(ctx, "", created.ShortCode, &models.UpdateLinkInput{Title: &title, Preview: &preview})
+	require.NoError(t, err)
+	assert.Equal(t, title, updated.Title)
+	assert.True(t, updated.Preview)
+
+	title += "ж"
+	_, err = linkService.UpdateLink(ctx, "", created.ShortCode, &models.UpdateLinkInput{Title: &title})
+	assert.ErrorIs(t, err, service.ErrInvalidTitle)
+	_, err = linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com", Title: title})
+	assert.ErrorIs(t, err, service.ErrInvalidTitle)
+}
+
+// TestLinkService_Password проверяет хранение пароля в виде хэша, проверку и снятие защиты
+func TestLinkService_Password(t *testing.T) {
+	linkService, linkRepo, _ := setupTestService()
+	ctx := context.Background()
+
+	_, err := linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com/short", Password: "abc"})
+	assert.ErrorIs(t, err, service.ErrInvalidPassword)
+
+	link, err := linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com/docs", Password: "s3cret-pass"})
+	require.NoError(t, err)
+	require.True(t, link.HasPassword())
+	assert.NotContains(t, link.PasswordHash, "s3cret-pass")
+
+	stored, err := linkRepo.GetByShortCode(ctx, "", link.ShortCode)
+	require.NoError(t, err)
+	assert.NoError(t, linkService.CheckPassword(ctx, stored, "s3cret-pass"))
+	assert.ErrorIs(t, linkService.CheckPassword(ctx, stored, "wrong"), service.ErrWrongPassword)
+
+	// Пустой пароль снимает защиту
+	empty := ""
+	updated, err := linkService.UpdateLink(ctx, "", link.ShortCode, &models.UpdateLinkInput{Password: &empty})
+	require.NoError(t, err)
+	assert.False(t, updated.HasPassword())
+	assert.NoError(t, linkService.CheckPassword(ctx, updated, ""))
+}
+
+// TestLinkService_ConsumeClick_SingleUse проверяет, что из одновременных переходов
+// по одноразовой ссылке засчитывается ровно один
+func TestLinkService_ConsumeClick_SingleUse(t *testing.T) {
+	linkService, _, _ := setupTestService()
+	ctx := context.Background()
+
+	_, err := linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com/negative", MaxClicks: -1})
+	assert.ErrorIs(t, err, service.ErrInvalidMaxClicks)
+
+	link, err := linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com/invite", MaxClicks: 1})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var consumed, exhausted atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			switch err := linkService.ConsumeClick(ctx, link); err {
+			case nil:
+				consumed.Add(1)
+			case service.ErrLinkExhausted:
+				exhausted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), consumed.Load())
+	assert.Equal(t, int32(49), exhausted.Load())
+
+	// Ссылки без лимита не обращаются к счётчику
+	unlimited, err := linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com/unlimited"})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, linkService.ConsumeClick(ctx, unlimited))
+	}
+}
+
+// TestLinkService_Schedule проверяет абсолютное время истечения и время активации
+func TestLinkService_Schedule(t *testing.T) {
+	linkService, _, cacheRepo := setupTestService()
+	ctx := context.Background()
+	now := time.Now()
+
+	expiresAt := now.Add(48 * time.Hour)
+	link, err := linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com/sale", ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+	require.NotNil(t, link.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*link.ExpiresAt))
+
+	expiresIn := 60
+	past := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+	for name, input := range map[string]*models.CreateLinkInput{
+		"оба варианта срока":     {ExpiresIn: &expiresIn, ExpiresAt: &expiresAt},
+		"истечение в прошлом":    {ExpiresAt: &past},
+		"активация после срока":  {StartsAt: &expiresAt, ExpiresAt: &later},
+		"активация после expiry": {StartsAt: &expiresAt, ExpiresIn: &expiresIn},
+	} {
+		input.OriginalURL = "https://example.com/invalid"
+		_, err := linkService.CreateLink(ctx, input)
+		assert.ErrorIs(t, err, service.ErrInvalidSchedule, name)
+	}
+
+	// До активации ссылка не отдаётся ни из кэша, ни из БД
+	startsAt := now.Add(time.Hour)
+	link, err = linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com/launch", StartsAt: &startsAt})
+	require.NoError(t, err)
+
+	_, err = linkService.GetLink(ctx, "", link.ShortCode)
+	assert.ErrorIs(t, err, service.ErrLinkNotActive)
+	require.NoError(t, cacheRepo.Delete(ctx, link.ShortCode))
+	_, err = linkService.GetLink(ctx, "", link.ShortCode)
+	assert.ErrorIs(t, err, service.ErrLinkNotActive)
+
+	// Время активации в прошлом — ссылка активна сразу
+	link, err = linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com/live", StartsAt: &past})
+	require.NoError(t, err)
+	_, err = linkService.GetLink(ctx, "", link.ShortCode)
+	assert.NoError(t, err)
+}
+
+// TestLinkService_ExpiredLink проверяет, что истёкшая ссылка отдаётся вместе с ErrLinkExpired
+func TestLinkService_ExpiredLink(t *testing.T) {
+	linkService, linkRepo, cacheRepo := setupTestService()
+	ctx := context.Background()
+
+	expired := time.Now().Add(-time.Hour)
+	require.NoError(t, linkRepo.Create(ctx, &models.Link{
+		ShortCode:   "promo001",
+		OriginalURL: "https://example.com/promo",
+		FallbackURL: "https://example.com/offers",
+		ExpiresAt:   &expired,
+	}))
+
+	// Из БД: ссылка нужна редиректу ради FallbackURL
+	link, err := linkService.GetLink(ctx, "", "promo001")
+	assert.ErrorIs(t, err, service.ErrLinkExpired)
+	require.NotNil(t, link)
+	assert.Equal(t, "https://example.com/offers", link.FallbackURL)
+
+	// В кэш истёкшая ссылка попадает ненадолго, а не без срока
+	ttl := cacheRepo.TTL("promo001")
+	assert.Greater(t, ttl, time.Duration(0))
+	assert.LessOrEqual(t, ttl, time.Minute)
+
+	// Из кэша
+	link, err = linkService.GetLink(ctx, "", "promo001")
+	assert.ErrorIs(t, err, service.ErrLinkExpired)
+	require.NotNil(t, link)
+
+	// Fallback URL проверяется как оригинальный
+	_, err = linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com", FallbackURL: "not-a-url"})
+	assert.ErrorIs(t, err, service.ErrInvalidFallbackURL)
+	_, err = linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com", FallbackURL: "https://spam.com/x"})
+	assert.ErrorIs(t, err, service.ErrInvalidFallbackURL)
+
+	fallback := "https://example.com/new-offers"
+	link, err = linkService.UpdateLink(ctx, "", "promo001", &models.UpdateLinkInput{FallbackURL: &fallback})
+	require.NoError(t, err)
+	assert.Equal(t, fallback, link.FallbackURL)
+}
+
+// TestLinkService_GeoRules проверяет нормализацию и валидацию правил геотаргетинга
+func TestLinkService_GeoRules(t *testing.T) {
+	linkService, _, _ := setupTestService()
+	ctx := context.Background()
+
+	link, err := linkService.CreateLink(ctx, &models.CreateLinkInput{
+		OriginalURL: "https://store.example.com",
+		GeoRules:    models.GeoRules{"de": "https://store.example.de", "FR": "https://store.example.fr"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.GeoRules{"DE": "https://store.example.de", "FR": "https://store.example.fr"}, link.GeoRules)
+
+	assert.Equal(t, models.Target{URL: "https://store.example.de", Rule: "geo:DE"}, link.Target(models.Visitor{Country: "DE"}))
+	assert.Equal(t, models.Target{URL: "https://store.example.com"}, link.Target(models.Visitor{}))
+
+	for name, rules := range map[string]models.GeoRules{
+		"трёхбуквенный код":  {"DEU": "https://store.example.de"},
+		"повтор кода":        {"de": "https://store.example.de", "DE": "https://store.example.de"},
+		"невалидный адрес":   {"DE": "store.example.de"},
+		"спам домен правила": {"DE": "https://spam.com/store"},
+	} {
+		_, err := linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://store.example.com", GeoRules: rules})
+		assert.ErrorIs(t, err, service.ErrInvalidGeoRules, name)
+	}
+
+	// Пустой объект удаляет правила
+	updated, err := linkService.UpdateLink(ctx, "", link.ShortCode, &models.UpdateLinkInput{GeoRules: models.GeoRules{}})
+	require.NoError(t, err)
+	assert.False(t, updated.HasRules())
+}
+
+// TestLinkService_DeviceRules проверяет валидацию правил по устройству и их приоритет над геотаргетингом
+func TestLinkService_DeviceRules(t *testing.T) {
+	linkService, _, _ := setupTestService()
+	ctx := context.Background()
+
+	link, err := linkService.CreateLink(ctx, &models.CreateLinkInput{
+		OriginalURL: "https://app.example.com",
+		GeoRules:    models.GeoRules{"DE": "https://app.example.de"},
+		DeviceRules: models.DeviceRules{
+			"iOS":     {URL: "https://apps.apple.com/app/id123", DeepLink: "myapp://home"},
+			"android": {URL: "https://play.google.com/store/apps/details?id=com.example"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, link.DeviceRules, models.PlatformIOS)
+
+	assert.Equal(t, models.Target{URL: "https://apps.apple.com/app/id123", DeepLink: "myapp://home", Rule: "device:ios"},
+		link.Target(models.Visitor{Country: "DE", Platform: models.PlatformIOS}))
+	assert.Equal(t, models.Target{URL: "https://app.example.de", Rule: "geo:DE"},
+		link.Target(models.Visitor{Country: "DE", Platform: models.PlatformDesktop}))
+
+	for name, rules := range map[string]models.DeviceRules{
+		"неизвестная платформа": {"windows": {URL: "https://app.example.com"}},
+		"повтор платформы":      {"ios": {URL: "https://a.example.com"}, "IOS": {URL: "https://b.example.com"}},
+		"невалидный адрес":      {"ios": {URL: "apps.apple.com"}},
+		"спам домен правила":    {"ios": {URL: "https://spam.com/app"}},
+		"deep link без схемы":   {"ios": {URL: "https://app.example.com", DeepLink: "home"}},
+		"javascript deep link":  {"ios": {URL: "https://app.example.com", DeepLink: "JavaScript:alert(1)"}},
+		"data deep link":        {"android": {URL: "https://app.example.com", DeepLink: "data:text/html,x"}},
+	} {
+		_, err := linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://app.example.com", DeviceRules: rules})
+		assert.ErrorIs(t, err, service.ErrInvalidDeviceRules, name)
+	}
+
+	// Пустой объект удаляет правила по устройству, геотаргетинг остаётся
+	updated, err := linkService.UpdateLink(ctx, "", link.ShortCode, &models.UpdateLinkInput{DeviceRules: models.DeviceRules{}})
+	require.NoError(t, err)
+	assert.Empty(t, updated.DeviceRules)
+	assert.True(t, updated.HasRules())
+}
+
+// TestLinkService_Variants проверяет валидацию вариантов A/B теста и замену их при изменении ссылки
+func TestLinkService_Variants(t *testing.T) {
+	linkService, _, _ := setupTestService()
+	ctx := context.Background()
+
+	link, err := linkService.CreateLink(ctx, &models.CreateLinkInput{
+		OriginalURL: "https://example.com/landing",
+		Variants: []models.Variant{
+			{Name: "control", URL: "https://example.com/landing", Weight: 70},
+			{Name: "new", URL: "https://example.com/landing-v2"},
+		},
+	})
+	require.NoError(t, err)
+	// Незаданный вес считается 1
+	assert.Equal(t, []models.Variant{
+		{Name: "control", URL: "https://example.com/landing", Weight: 70},
+		{Name: "new", URL: "https://example.com/landing-v2", Weight: 1},
+	}, link.Variants)
+
+	valid := models.Variant{Name: "control", URL: "https://example.com/landing"}
+	for name, variants := range map[string][]models.Variant{
+		"один вариант":        {valid},
+		"повтор имени":        {valid, valid},
+		"невалидное имя":      {valid, {Name: "new variant", URL: "https://example.com/v2"}},
+		"невалидный адрес":    {valid, {Name: "new", URL: "example.com/v2"}},
+		"спам домен варианта": {valid, {Name: "new", URL: "https://spam.com/v2"}},
+		"отрицательный вес":   {valid, {Name: "new", URL: "https://example.com/v2", Weight: -1}},
+		"слишком большой вес": {valid, {Name: "new", URL: "https://example.com/v2", Weight: 1001}},
+	} {
+		_, err := linkService.CreateLink(ctx, &models.CreateLinkInput{OriginalURL: "https://example.com", Variants: variants})
+		assert.ErrorIs(t, err, service.ErrInvalidVariants, name)
+	}
+
+	// Пустой список завершает тест
+	updated, err := linkService.UpdateLink(ctx, "", link.ShortCode, &models.UpdateLinkInput{Variants: []models.Variant{}})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Variants)
+	assert.False(t, updated.HasRules())
+}
+
+// TestLinkService_GetLink_NegativeCache проверяет кэширование отсутствующего кода
+// и его сброс при создании ссылки с этим кодом
+func TestLinkService_GetLink_NegativeCache(t *testing.T) {
+	linkRepo := &countingLinkRepository{MockLinkRepository: mocks.NewMockLinkRepository()}
+	cacheRepo := mocks.NewMockCacheRepository()
+	linkService := service.NewLinkService(linkRepo, cacheRepo, service.LinkServiceConfig{}, zap.NewNop())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := linkService.GetLink(ctx, "", "unknown1")
+		assert.ErrorIs(t, err, repository.ErrLinkNotFound)
+	}
+	assert.Equal(t, int32(1), linkRepo.lookups.Load(), "повторные запросы должны отвечаться из кэша")
+	assert.True(t, cacheRepo.IsNotFound("unknown1"))
+
+	// Код занимают — negative-запись больше не действует
+	code := "unknown1"
+	_, err := linkService.CreateLink(ctx, &models.CreateLinkInput{
+		OriginalURL: "https://example.com/claimed",
+		CustomCode:  &code,
+	})
+	require.NoError(t, err)
+
+	link, err := linkService.GetLink(ctx, "", code)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/claimed", link.OriginalURL)
+}
+
+// TestLinkService_GetLink_CoalescesLookups проверяет, что параллельные промахи
+// по одному коду выполняют один запрос в БД
+func TestLinkService_GetLink_CoalescesLookups(t *testing.T) {
+	linkRepo := &countingLinkRepository{
+		MockLinkRepository: mocks.NewMockLinkRepository(),
+		delay:              50 * time.Millisecond,
+	}
+	linkService := service.NewLinkService(linkRepo, mocks.NewMockCacheRepository(), service.LinkServiceConfig{}, zap.NewNop())
+	ctx := context.Background()
+
+	require.NoError(t, linkRepo.Create(ctx, &models.Link{ShortCode: "hotlink1", OriginalURL: "https://example.com"}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			link, err := linkService.GetLink(ctx, "", "hotlink1")
+			assert.NoError(t, err)
+			assert.Equal(t, "hotlink1", link.ShortCode)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), linkRepo.lookups.Load())
+}
+
+// countingLinkRepository считает обращения к БД за ссылкой и может их замедлять
+type countingLinkRepository struct {
+	*mocks.MockLinkRepository
+	lookups atomic.Int32
+	delay   time.Duration
+}
+
+func (r *countingLinkRepository) GetByShortCode(ctx context.Context, domain, code string) (*models.Link, error) {
+	r.lookups.Add(1)
+	time.Sleep(r.delay)
+	return r.MockLinkRepository.GetByShortCode(ctx, domain, code)
+}
+
 // TestLinkService_DeleteLink_Success проверяет успешное удаление ссылки
 func TestLinkService_DeleteLink_Success(t *testing.T) {
 	linkService, linkRepo, cacheRepo := setupTestService()
@@ -178,7 +619,7 @@ func TestLinkService_DeleteLink_Success(t *testing.T) {
 	require.NoError(t, err)
 
 	// Удаляем ссылку
-	err = linkService.DeleteLink(ctx, createdLink.ShortCode)
+	err = linkService.DeleteLink(ctx, "", createdLink.ShortCode)
 	require.NoError(t, err)
 
 	// Проверяем, что ссылка удалена из кэша
@@ -186,7 +627,7 @@ func TestLinkService_DeleteLink_Success(t *testing.T) {
 	assert.Error(t, err)
 
 	// Проверяем, что ссылка удалена из БД
-	_, err = linkRepo.GetByShortCode(ctx, createdLink.ShortCode)
+	_, err = linkRepo.GetByShortCode(ctx, "", createdLink.ShortCode)
 	assert.Error(t, err)
 }
 
@@ -195,7 +636,7 @@ func TestLinkService_DeleteLink_NotFound(t *testing.T) {
 	linkService, _, _ := setupTestService()
 
 	ctx := context.Background()
-	err := linkService.DeleteLink(ctx, "nonexistent")
+	err := linkService.DeleteLink(ctx, "", "nonexistent")
 
 	assert.Error(t, err)
 }
@@ -2,6 +2,9 @@ package mocks
 
 import (
 	"context"
+	"maps"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,14 +15,16 @@ import (
 // MockLinkRepository implements repository.LinkRepository for testing
 type MockLinkRepository struct {
 	mu      sync.RWMutex
-	links   map[string]*models.Link
+	links   map[string]*models.Link // models.LinkKey -> link
+	retired map[string]bool
 	nextID  int64
 }
 
 func NewMockLinkRepository() *MockLinkRepository {
 	return &MockLinkRepository{
-		links:  make(map[string]*models.Link),
-		nextID: 1,
+		links:   make(map[string]*models.Link),
+		retired: make(map[string]bool),
+		nextID:  1,
 	}
 }
 
@@ -27,65 +32,219 @@ func (m *MockLinkRepository) Create(ctx context.Context, link *models.Link) erro
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.links[link.ShortCode]; exists {
+	key := models.LinkKey(link.Domain, link.ShortCode)
+	if _, exists := m.links[key]; exists || m.retired[key] {
 		return repository.ErrCodeExists
 	}
 
 	link.ID = m.nextID
 	m.nextID++
-	m.links[link.ShortCode] = link
+	m.links[key] = link
 	return nil
 }
 
-func (m *MockLinkRepository) GetByShortCode(ctx context.Context, code string) (*models.Link, error) {
+func (m *MockLinkRepository) GetByShortCode(ctx context.Context, domain, code string) (*models.Link, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	link, exists := m.links[code]
+	link, exists := m.links[models.LinkKey(domain, code)]
 	if !exists {
 		return nil, repository.ErrLinkNotFound
 	}
 	return link, nil
 }
 
-func (m *MockLinkRepository) Delete(ctx context.Context, code string) error {
+// GetByShortCodePrimary в моке не отличается от GetByShortCode: реплик нет
+func (m *MockLinkRepository) GetByShortCodePrimary(ctx context.Context, domain, code string) (*models.Link, error) {
+	return m.GetByShortCode(ctx, domain, code)
+}
+
+func (m *MockLinkRepository) Update(ctx context.Context, domain, code string, input *models.UpdateLinkInput) (*models.Link, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := models.LinkKey(domain, code)
+	link, exists := m.links[key]
+	if !exists {
+		return nil, repository.ErrLinkNotFound
+	}
+
+	updated := *link
+	if input.RedirectType != nil {
+		updated.RedirectType = *input.RedirectType
+	}
+	if input.PasswordHash != nil {
+		updated.PasswordHash = *input.PasswordHash
+	}
+	if input.MaxClicks != nil {
+		updated.MaxClicks = *input.MaxClicks
+	}
+	if input.FallbackURL != nil {
+		updated.FallbackURL = *input.FallbackURL
+	}
+	if input.Title != nil {
+		updated.Title = *input.Title
+	}
+	if input.Preview != nil {
+		updated.Preview = *input.Preview
+	}
+	if input.GeoRules != nil {
+		updated.GeoRules = input.GeoRules
+	}
+	if input.DeviceRules != nil {
+		updated.DeviceRules = input.DeviceRules
+	}
+	if input.Variants != nil {
+		updated.Variants = input.Variants
+	}
+	m.links[key] = &updated
+	return &updated, nil
+}
+
+func (m *MockLinkRepository) Delete(ctx context.Context, domain, code string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.links[code]; !exists {
+	key := models.LinkKey(domain, code)
+	if _, exists := m.links[key]; !exists {
 		return repository.ErrLinkNotFound
 	}
-	delete(m.links, code)
+	delete(m.links, key)
 	return nil
 }
 
-func (m *MockLinkRepository) GetLinkIDByShortCode(ctx context.Context, code string) (int64, error) {
+func (m *MockLinkRepository) ClicksLeft(ctx context.Context, id int64) (bool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	link, exists := m.links[code]
+	for _, link := range m.links {
+		if link.ID == id {
+			return link.UsedClicks < link.MaxClicks, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MockLinkRepository) ConsumeClick(ctx context.Context, id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, link := range m.links {
+		if link.ID != id {
+			continue
+		}
+		if link.UsedClicks >= link.MaxClicks {
+			return false, nil
+		}
+		updated := *link
+		updated.UsedClicks++
+		m.links[key] = &updated
+		return true, nil
+	}
+	return false, nil
+}
+
+func (m *MockLinkRepository) GetLinkIDByShortCode(ctx context.Context, domain, code string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	link, exists := m.links[models.LinkKey(domain, code)]
 	if !exists {
 		return 0, repository.ErrLinkNotFound
 	}
 	return link.ID, nil
 }
 
+// GetTopLinks возвращает до limit ссылок в порядке возрастания ID (клики в моке не учитываются)
+func (m *MockLinkRepository) GetTopLinks(ctx context.Context, since time.Time, limit int) ([]*models.Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	links := make([]*models.Link, 0, len(m.links))
+	for _, link := range m.links {
+		links = append(links, link)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].ID < links[j].ID })
+	if len(links) > limit {
+		links = links[:limit]
+	}
+	return links, nil
+}
+
+// PurgeExpired удаляет истёкшие ссылки в порядке возрастания ID (архив в моке не хранится)
+func (m *MockLinkRepository) PurgeExpired(ctx context.Context, before time.Time, limit int, opts repository.PurgeOptions) ([]*models.Link, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expired := make([]*models.Link, 0)
+	for _, link := range m.links {
+		if link.ExpiresAt != nil && link.ExpiresAt.Before(before) {
+			expired = append(expired, link)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].ID < expired[j].ID })
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	for _, link := range expired {
+		key := models.LinkKey(link.Domain, link.ShortCode)
+		delete(m.links, key)
+		if opts.RetireCodes {
+			m.retired[key] = true
+		}
+	}
+	return expired, nil
+}
+
 func (m *MockLinkRepository) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.links = make(map[string]*models.Link)
+	m.retired = make(map[string]bool)
 	m.nextID = 1
 }
 
+// MockLocker implements repository.Locker for testing
+type MockLocker struct {
+	mu     sync.Mutex
+	locked map[string]bool
+}
+
+func NewMockLocker() *MockLocker {
+	return &MockLocker{locked: make(map[string]bool)}
+}
+
+func (m *MockLocker) TryLock(ctx context.Context, name string, fn func(ctx context.Context) error) (bool, error) {
+	m.mu.Lock()
+	if m.locked[name] {
+		m.mu.Unlock()
+		return false, nil
+	}
+	m.locked[name] = true
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.locked, name)
+		m.mu.Unlock()
+	}()
+	return true, fn(ctx)
+}
+
 // MockCacheRepository implements repository.CacheRepository for testing
 type MockCacheRepository struct {
-	mu    sync.RWMutex
-	cache map[string]*models.Link
+	mu       sync.RWMutex
+	cache    map[string]*models.Link
+	ttls     map[string]time.Duration
+	notFound map[string]bool
 }
 
 func NewMockCacheRepository() *MockCacheRepository {
 	return &MockCacheRepository{
-		cache: make(map[string]*models.Link),
+		cache:    make(map[string]*models.Link),
+		ttls:     make(map[string]time.Duration),
+		notFound: make(map[string]bool),
 	}
 }
 
@@ -93,9 +252,12 @@ func (m *MockCacheRepository) Get(ctx context.Context, key string) (*models.Link
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	if m.notFound[key] {
+		return nil, repository.ErrLinkNotFound
+	}
 	link, exists := m.cache[key]
 	if !exists {
-		return nil, repository.ErrLinkNotFound
+		return nil, repository.ErrCacheMiss
 	}
 	return link, nil
 }
@@ -103,14 +265,41 @@ func (m *MockCacheRepository) Get(ctx context.Context, key string) (*models.Link
 func (m *MockCacheRepository) Set(ctx context.Context, key string, link *models.Link, ttl time.Duration) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	delete(m.notFound, key)
 	m.cache[key] = link
+	m.ttls[key] = ttl
+	return nil
+}
+
+// TTL время жизни, с которым ссылка записана в кэш
+func (m *MockCacheRepository) TTL(key string) time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ttls[key]
+}
+
+func (m *MockCacheRepository) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.cache[key]; !exists {
+		m.notFound[key] = true
+	}
 	return nil
 }
 
+// IsNotFound сообщает, есть ли negative-запись для ключа
+func (m *MockCacheRepository) IsNotFound(key string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.notFound[key]
+}
+
 func (m *MockCacheRepository) Delete(ctx context.Context, key string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	delete(m.cache, key)
+	delete(m.ttls, key)
+	delete(m.notFound, key)
 	return nil
 }
 
@@ -118,6 +307,8 @@ func (m *MockCacheRepository) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.cache = make(map[string]*models.Link)
+	m.ttls = make(map[string]time.Duration)
+	m.notFound = make(map[string]bool)
 }
 
 // MockClickRepository implements repository.ClickRepository for testing
@@ -139,35 +330,54 @@ func (m *MockClickRepository) RecordClick(ctx context.Context, click *models.Cli
 	return nil
 }
 
-func (m *MockClickRepository) GetStats(ctx context.Context, shortCode string) (*models.ClickStats, error) {
+// GetStats считает клики по коду (домен в моке не учитывается)
+func (m *MockClickRepository) GetStats(ctx context.Context, domain, shortCode string) (*models.ClickStats, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	// Find link by short code and count clicks
 	var totalClicks int64
 	uniqueIPs := make(map[string]bool)
+	variants := make(map[string]*models.VariantClickStats)
+	variantIPs := make(map[string]map[string]bool)
 
 	for _, clicks := range m.clicks {
 		for _, click := range clicks {
-			if click.ShortCode == shortCode {
-				totalClicks++
-				uniqueIPs[click.IPAddress] = true
+			if click.ShortCode != shortCode {
+				continue
+			}
+			totalClicks++
+			uniqueIPs[click.IPAddress] = true
+			if click.Variant == "" {
+				continue
 			}
+			if variants[click.Variant] == nil {
+				variants[click.Variant] = &models.VariantClickStats{Variant: click.Variant}
+				variantIPs[click.Variant] = make(map[string]bool)
+			}
+			variants[click.Variant].TotalClicks++
+			variantIPs[click.Variant][click.IPAddress] = true
 		}
 	}
 
-	return &models.ClickStats{
+	stats := &models.ClickStats{
 		ShortCode:    shortCode,
 		TotalClicks:  totalClicks,
 		UniqueClicks: int64(len(uniqueIPs)),
-	}, nil
+	}
+	for _, name := range slices.Sorted(maps.Keys(variants)) {
+		variant := variants[name]
+		variant.UniqueClicks = int64(len(variantIPs[name]))
+		stats.Variants = append(stats.Variants, *variant)
+	}
+	return stats, nil
 }
 
-func (m *MockClickRepository) GetDailyStats(ctx context.Context, shortCode string, days int) ([]models.DailyClickStats, error) {
+func (m *MockClickRepository) GetDailyStats(ctx context.Context, domain, shortCode string, days int) ([]models.DailyClickStats, error) {
 	return []models.DailyClickStats{}, nil
 }
 
-func (m *MockClickRepository) GetLinkIDByShortCode(ctx context.Context, shortCode string) (int64, error) {
+func (m *MockClickRepository) GetLinkIDByShortCode(ctx context.Context, domain, shortCode string) (int64, error) {
 	return 0, nil
 }
 
@@ -176,3 +386,65 @@ func (m *MockClickRepository) Reset() {
 	defer m.mu.Unlock()
 	m.clicks = make(map[int64][]*models.Click)
 }
+
+// MockClickPartitionRepository implements repository.ClickPartitionRepository for testing
+type MockClickPartitionRepository struct {
+	mu         sync.Mutex
+	partitions map[string]time.Time // name -> month
+	Archived   []string
+	// NotPartitioned имитирует базу, в которой таблица clicks ещё не партиционирована
+	NotPartitioned bool
+}
+
+func NewMockClickPartitionRepository() *MockClickPartitionRepository {
+	return &MockClickPartitionRepository{
+		partitions: make(map[string]time.Time),
+	}
+}
+
+func (m *MockClickPartitionRepository) Partitioned(ctx context.Context) (bool, error) {
+	return !m.NotPartitioned, nil
+}
+
+func (m *MockClickPartitionRepository) EnsurePartitions(ctx context.Context, from time.Time, months int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var created []string
+	start := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= months; i++ {
+		month := start.AddDate(0, i, 0)
+		name := month.Format("clicks_p2006_01")
+		if _, exists := m.partitions[name]; !exists {
+			m.partitions[name] = month
+			created = append(created, name)
+		}
+	}
+	return created, nil
+}
+
+func (m *MockClickPartitionRepository) ListPartitions(ctx context.Context) ([]models.ClickPartition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	partitions := make([]models.ClickPartition, 0, len(m.partitions))
+	for name, month := range m.partitions {
+		partitions = append(partitions, models.ClickPartition{Name: name, Month: month})
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].Month.Before(partitions[j].Month) })
+	return partitions, nil
+}
+
+func (m *MockClickPartitionRepository) RemovePartition(ctx context.Context, name string, archive bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.partitions[name]; !exists {
+		return false, nil
+	}
+	delete(m.partitions, name)
+	if archive {
+		m.Archived = append(m.Archived, name)
+	}
+	return true, nil
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SergeiKhy/url-shortener/internal/metrics"
+	"github.com/SergeiKhy/url-shortener/internal/repository"
+	"go.uber.org/zap"
+)
+
+// Константы обслуживания партиций по умолчанию
+const (
+	defaultPartitionPremake  = 3
+	defaultPartitionInterval = time.Hour
+)
+
+// PartitionManagerConfig конфигурация обслуживания партиций кликов
+type PartitionManagerConfig struct {
+	Premake         int           // На сколько месяцев вперёд создавать партиции
+	RetentionMonths int           // Сколько полных месяцев хранить клики кроме текущего, 0 — бессрочно
+	Archive         bool          // Переносить старые партиции в схему архива вместо удаления
+	Interval        time.Duration // Интервал запуска обслуживания
+}
+
+// PartitionManager создаёт партиции кликов заранее и отсоединяет партиции старше срока хранения,
+// чтобы не выполнять DELETE по огромной таблице
+type PartitionManager interface {
+	Start()
+	Stop()
+	// Maintain выполняет один проход обслуживания
+	Maintain(ctx context.Context) error
+}
+
+// partitionManager реализация обслуживания партиций
+type partitionManager struct {
+	repo   repository.ClickPartitionRepository
+	config PartitionManagerConfig
+	logger *zap.Logger
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+// NewPartitionManager создаёт новый экземпляр обслуживания партиций
+func NewPartitionManager(
+	repo repository.ClickPartitionRepository,
+	config PartitionManagerConfig,
+	logger *zap.Logger,
+) PartitionManager {
+	if config.Premake <= 0 {
+		config.Premake = defaultPartitionPremake
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultPartitionInterval
+	}
+
+	return &partitionManager{
+		repo:   repo,
+		config: config,
+		logger: logger,
+	}
+}
+
+// Start сразу выполняет обслуживание и затем повторяет его каждые Interval. Если таблица
+// clicks не партиционирована (миграции не применены), обслуживание не запускается.
+func (m *partitionManager) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		partitioned, err := m.repo.Partitioned(ctx)
+		if err != nil {
+			// Проверка не удалась (например, БД недоступна): обслуживание запускается,
+			// и его ошибки попадут в лог
+			m.logger.Error("Не удалось проверить партиционирование кликов", zap.Error(err))
+		} else if !partitioned {
+			m.logger.Warn("Таблица clicks не партиционирована, обслуживание партиций отключено: примените миграции и перезапустите сервис")
+			return
+		}
+
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := m.Maintain(ctx); err != nil && ctx.Err() == nil {
+				m.logger.Error("Ошибка обслуживания партиций кликов", zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop останавливает фоновое обслуживание
+func (m *partitionManager) Stop() {
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	m.wg.Wait()
+}
+
+func (m *partitionManager) Maintain(ctx context.Context) error {
+	now := time.Now()
+
+	// Ошибка одного месяца не останавливает создание остальных партиций и очистку старых
+	created, ensureErr := m.repo.EnsurePartitions(ctx, now, m.config.Premake)
+	for _, name := range created {
+		metrics.ClickPartitionOps.WithLabelValues(metrics.PartitionCreated).Inc()
+		m.logger.Info("Создана партиция кликов", zap.String("partition", name))
+	}
+
+	if m.config.RetentionMonths <= 0 {
+		return ensureErr
+	}
+
+	// Партиция удаляется целиком, когда самый новый клик в ней старше срока хранения
+	cutoff := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -m.config.RetentionMonths, 0)
+
+	partitions, err := m.repo.ListPartitions(ctx)
+	if err != nil {
+		return errors.Join(ensureErr, err)
+	}
+
+	op := metrics.PartitionDropped
+	if m.config.Archive {
+		op = metrics.PartitionArchived
+	}
+	for _, partition := range partitions {
+		if !partition.Month.Before(cutoff) {
+			continue
+		}
+		removed, err := m.repo.RemovePartition(ctx, partition.Name, m.config.Archive)
+		if err != nil {
+			return errors.Join(ensureErr, err)
+		}
+		if removed {
+			metrics.ClickPartitionOps.WithLabelValues(op).Inc()
+			m.logger.Info("Партиция кликов старше срока хранения отсоединена",
+				zap.String("partition", partition.Name),
+				zap.Bool("archived", m.config.Archive),
+			)
+		}
+	}
+
+	return ensureErr
+}
@@ -0,0 +1,92 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/SergeiKhy/url-shortener/internal/service"
+	"github.com/SergeiKhy/url-shortener/internal/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func monthName(t time.Time, offset int) string {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, offset, 0).Format("clicks_p2006_01")
+}
+
+func partitionNames(t *testing.T, repo *mocks.MockClickPartitionRepository) []string {
+	partitions, err := repo.ListPartitions(context.Background())
+	require.NoError(t, err)
+	names := make([]string, 0, len(partitions))
+	for _, p := range partitions {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// TestPartitionManager_Maintain проверяет создание партиций вперёд и удаление старше срока хранения
+func TestPartitionManager_Maintain(t *testing.T) {
+	repo := mocks.NewMockClickPartitionRepository()
+	ctx := context.Background()
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	// Партиции за последние полгода
+	_, err := repo.EnsurePartitions(ctx, month.AddDate(0, -6, 0), 6)
+	require.NoError(t, err)
+
+	manager := service.NewPartitionManager(repo, service.PartitionManagerConfig{
+		Premake:         2,
+		RetentionMonths: 3,
+	}, zap.NewNop())
+	require.NoError(t, manager.Maintain(ctx))
+
+	assert.Equal(t, []string{
+		monthName(now, -3), monthName(now, -2), monthName(now, -1),
+		monthName(now, 0), monthName(now, 1), monthName(now, 2),
+	}, partitionNames(t, repo))
+	assert.Empty(t, repo.Archived)
+
+	// Повторный проход ничего не меняет
+	require.NoError(t, manager.Maintain(ctx))
+	assert.Len(t, partitionNames(t, repo), 6)
+}
+
+// TestPartitionManager_Archive проверяет архивирование вместо удаления и бессрочное хранение
+func TestPartitionManager_Archive(t *testing.T) {
+	repo := mocks.NewMockClickPartitionRepository()
+	ctx := context.Background()
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := repo.EnsurePartitions(ctx, month.AddDate(0, -2, 0), 0)
+	require.NoError(t, err)
+
+	keep := service.NewPartitionManager(repo, service.PartitionManagerConfig{}, zap.NewNop())
+	require.NoError(t, keep.Maintain(ctx))
+	assert.Contains(t, partitionNames(t, repo), monthName(now, -2), "без срока хранения партиции не удаляются")
+	assert.Contains(t, partitionNames(t, repo), monthName(now, 3), "по умолчанию партиции создаются на 3 месяца вперёд")
+
+	archive := service.NewPartitionManager(repo, service.PartitionManagerConfig{
+		RetentionMonths: 1,
+		Archive:         true,
+	}, zap.NewNop())
+	require.NoError(t, archive.Maintain(ctx))
+	assert.Equal(t, []string{monthName(now, -2)}, repo.Archived)
+}
+
+// TestPartitionManager_SkipsUnpartitioned проверяет, что обслуживание не запускается,
+// пока таблица clicks не партиционирована
+func TestPartitionManager_SkipsUnpartitioned(t *testing.T) {
+	repo := mocks.NewMockClickPartitionRepository()
+	repo.NotPartitioned = true
+
+	manager := service.NewPartitionManager(repo, service.PartitionManagerConfig{}, zap.NewNop())
+	manager.Start()
+	manager.Stop()
+
+	assert.Empty(t, partitionNames(t, repo))
+}
@@ -1 +1,27 @@
 package service
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/SergeiKhy/url-shortener/internal/repository"
+)
+
+// tracer трейсер OpenTelemetry для спанов сервисного слоя
+var tracer = otel.Tracer("github.com/SergeiKhy/url-shortener/internal/service")
+
+// recordError отмечает ошибку в спане и возвращает её без изменений.
+// Отсутствие ссылки и ещё не активная ссылка — штатные ситуации и не помечают спан как ошибочный.
+func recordError(span trace.Span, err error) error {
+	if err == nil {
+		return nil
+	}
+	span.RecordError(err)
+	if !errors.Is(err, repository.ErrLinkNotFound) && !errors.Is(err, ErrLinkNotActive) && !errors.Is(err, ErrLinkExpired) {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
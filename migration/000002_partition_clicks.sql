@@ -0,0 +1,79 @@
+-- +migrate Up
+-- Клики переносятся в таблицу с помесячными партициями. Существующие строки копируются,
+-- поэтому на больших объёмах миграцию стоит запускать в окно обслуживания.
+ALTER TABLE clicks RENAME TO clicks_unpartitioned;
+ALTER TABLE clicks_unpartitioned RENAME CONSTRAINT clicks_pkey TO clicks_unpartitioned_pkey;
+ALTER SEQUENCE clicks_id_seq RENAME TO clicks_unpartitioned_id_seq;
+DROP INDEX IF EXISTS idx_clicks_link_id;
+DROP INDEX IF EXISTS idx_clicks_clicked_at;
+
+CREATE TABLE clicks (
+    id BIGSERIAL,
+    link_id INTEGER REFERENCES links(id) ON DELETE CASCADE,
+    ip_address INET,
+    user_agent TEXT,
+    referer TEXT,
+    country VARCHAR(2),
+    clicked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, clicked_at)
+) PARTITION BY RANGE (clicked_at);
+
+-- Статистика по ссылке за период и выборка популярных ссылок
+CREATE INDEX idx_clicks_link_id_clicked_at ON clicks(link_id, clicked_at);
+CREATE INDEX idx_clicks_clicked_at ON clicks(clicked_at);
+
+-- Партиции с месяца самого старого клика и на три месяца вперёд,
+-- дальше их создаёт фоновая задача сервиса
+DO $$
+DECLARE
+    partition_start TIMESTAMP := date_trunc('month', COALESCE((SELECT MIN(clicked_at) FROM clicks_unpartitioned), LOCALTIMESTAMP));
+BEGIN
+    WHILE partition_start <= date_trunc('month', LOCALTIMESTAMP) + INTERVAL '3 months' LOOP
+        EXECUTE format(
+            'CREATE TABLE %I PARTITION OF clicks FOR VALUES FROM (%L) TO (%L)',
+            'clicks_p' || to_char(partition_start, 'YYYY_MM'), partition_start, partition_start + INTERVAL '1 month'
+        );
+        partition_start := partition_start + INTERVAL '1 month';
+    END LOOP;
+END
+$$;
+
+-- Страховка на случай, если партиция на нужный месяц ещё не создана
+CREATE TABLE clicks_default PARTITION OF clicks DEFAULT;
+
+INSERT INTO clicks (id, link_id, ip_address, user_agent, referer, country, clicked_at)
+SELECT id, link_id, ip_address, user_agent, referer, country, COALESCE(clicked_at, NOW())
+FROM clicks_unpartitioned;
+
+SELECT setval('clicks_id_seq', COALESCE((SELECT MAX(id) FROM clicks), 0) + 1, false);
+
+DROP TABLE clicks_unpartitioned;
+
+-- +migrate Down
+-- Партиции, перенесённые в схему clicks_archive, не возвращаются
+ALTER TABLE clicks RENAME TO clicks_partitioned;
+ALTER TABLE clicks_partitioned RENAME CONSTRAINT clicks_pkey TO clicks_partitioned_pkey;
+ALTER SEQUENCE clicks_id_seq RENAME TO clicks_partitioned_id_seq;
+DROP INDEX IF EXISTS idx_clicks_link_id_clicked_at;
+DROP INDEX IF EXISTS idx_clicks_clicked_at;
+
+CREATE TABLE clicks (
+    id SERIAL PRIMARY KEY,
+    link_id INTEGER REFERENCES links(id) ON DELETE CASCADE,
+    ip_address INET,
+    user_agent TEXT,
+    referer TEXT,
+    country VARCHAR(2),
+    clicked_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO clicks (id, link_id, ip_address, user_agent, referer, country, clicked_at)
+SELECT id, link_id, ip_address, user_agent, referer, country, clicked_at
+FROM clicks_partitioned;
+
+SELECT setval('clicks_id_seq', COALESCE((SELECT MAX(id) FROM clicks), 0) + 1, false);
+
+DROP TABLE clicks_partitioned;
+
+CREATE INDEX IF NOT EXISTS idx_clicks_link_id ON clicks(link_id);
+CREATE INDEX IF NOT EXISTS idx_clicks_clicked_at ON clicks(clicked_at);
@@ -0,0 +1,22 @@
+-- +migrate Up
+-- Истёкшие ссылки, перенесённые из links фоновой очисткой в режиме архивирования
+CREATE TABLE IF NOT EXISTS links_archive (
+    id INTEGER PRIMARY KEY,
+    short_code VARCHAR(12) NOT NULL,
+    original_url TEXT NOT NULL,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP,
+    archived_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_links_archive_short_code ON links_archive(short_code);
+
+-- Коды удалённых ссылок, которые нельзя выдать повторно
+CREATE TABLE IF NOT EXISTS retired_codes (
+    short_code VARCHAR(12) PRIMARY KEY,
+    retired_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS retired_codes;
+DROP TABLE IF EXISTS links_archive;
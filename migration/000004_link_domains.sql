@@ -0,0 +1,27 @@
+-- +migrate Up
+-- Ссылки на нескольких доменах: один код может существовать на разных доменах.
+-- Пустой домен — домен по умолчанию (APP_BASE_URL), в том числе для существующих ссылок.
+ALTER TABLE links ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE links DROP CONSTRAINT links_short_code_key;
+ALTER TABLE links ADD CONSTRAINT links_domain_short_code_key UNIQUE (domain, short_code);
+-- Поиск по коду покрывает уникальный индекс (domain, short_code)
+DROP INDEX IF EXISTS idx_short_code;
+
+ALTER TABLE links_archive ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '';
+
+ALTER TABLE retired_codes ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE retired_codes DROP CONSTRAINT retired_codes_pkey;
+ALTER TABLE retired_codes ADD PRIMARY KEY (domain, short_code);
+
+-- +migrate Down
+-- Откат невозможен, если один код уже занят на нескольких доменах
+ALTER TABLE retired_codes DROP CONSTRAINT retired_codes_pkey;
+ALTER TABLE retired_codes DROP COLUMN domain;
+ALTER TABLE retired_codes ADD PRIMARY KEY (short_code);
+
+ALTER TABLE links_archive DROP COLUMN domain;
+
+CREATE INDEX IF NOT EXISTS idx_short_code ON links(short_code);
+ALTER TABLE links DROP CONSTRAINT links_domain_short_code_key;
+ALTER TABLE links DROP COLUMN domain;
+ALTER TABLE links ADD CONSTRAINT links_short_code_key UNIQUE (short_code);
@@ -0,0 +1,7 @@
+-- +migrate Up
+-- HTTP статус редиректа ссылки. Существующие ссылки сохраняют прежнее поведение (307).
+ALTER TABLE links ADD COLUMN redirect_type SMALLINT NOT NULL DEFAULT 307
+    CONSTRAINT links_redirect_type_check CHECK (redirect_type IN (301, 302, 307, 308));
+
+-- +migrate Down
+ALTER TABLE links DROP COLUMN redirect_type;
@@ -0,0 +1,6 @@
+-- +migrate Up
+-- bcrypt хэш пароля ссылки, пустая строка — ссылка без пароля
+ALTER TABLE links ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE links DROP COLUMN password_hash;
@@ -0,0 +1,11 @@
+-- +migrate Up
+-- Ограничение числа переходов: max_clicks = 0 — без ограничения.
+-- used_clicks увеличивается условным UPDATE, поэтому одновременные переходы
+-- по одноразовой ссылке не могут превысить лимит.
+ALTER TABLE links ADD COLUMN max_clicks INTEGER NOT NULL DEFAULT 0
+    CONSTRAINT links_max_clicks_check CHECK (max_clicks >= 0);
+ALTER TABLE links ADD COLUMN used_clicks INTEGER NOT NULL DEFAULT 0;
+
+-- +migrate Down
+ALTER TABLE links DROP COLUMN used_clicks;
+ALTER TABLE links DROP COLUMN max_clicks;
@@ -0,0 +1,9 @@
+-- +migrate Up
+-- Время активации ссылки: до starts_at ссылка не открывается (NULL — активна сразу)
+ALTER TABLE links ADD COLUMN starts_at TIMESTAMP;
+ALTER TABLE links ADD CONSTRAINT links_schedule_check
+    CHECK (starts_at IS NULL OR expires_at IS NULL OR starts_at < expires_at);
+
+-- +migrate Down
+ALTER TABLE links DROP CONSTRAINT links_schedule_check;
+ALTER TABLE links DROP COLUMN starts_at;
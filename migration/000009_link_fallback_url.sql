@@ -0,0 +1,6 @@
+-- +migrate Up
+-- Адрес, на который переходит истёкшая ссылка (пустой — страница 410)
+ALTER TABLE links ADD COLUMN fallback_url TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE links DROP COLUMN fallback_url;
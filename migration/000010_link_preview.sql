@@ -0,0 +1,8 @@
+-- +migrate Up
+-- Заголовок ссылки для страницы предпросмотра и флаг обязательного предпросмотра
+ALTER TABLE links ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN preview BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +migrate Down
+ALTER TABLE links DROP COLUMN preview;
+ALTER TABLE links DROP COLUMN title;
@@ -0,0 +1,9 @@
+-- +migrate Up
+-- Правила геотаргетинга: код страны (ISO 3166-1 alpha-2) → адрес назначения
+ALTER TABLE links ADD COLUMN geo_rules JSONB NOT NULL DEFAULT '{}';
+-- Правило, по которому выбран адрес перехода (пусто — адрес ссылки по умолчанию)
+ALTER TABLE clicks ADD COLUMN rule TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE clicks DROP COLUMN rule;
+ALTER TABLE links DROP COLUMN geo_rules;
@@ -0,0 +1,6 @@
+-- +migrate Up
+-- Правила по устройству: платформа (ios, android, desktop) → {"url": ..., "deep_link": ...}
+ALTER TABLE links ADD COLUMN device_rules JSONB NOT NULL DEFAULT '{}';
+
+-- +migrate Down
+ALTER TABLE links DROP COLUMN device_rules;
@@ -0,0 +1,19 @@
+-- +migrate Up
+-- Варианты A/B теста ссылки: трафик делится между адресами пропорционально весам
+CREATE TABLE IF NOT EXISTS link_variants (
+    id SERIAL PRIMARY KEY,
+    link_id INTEGER NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+    position SMALLINT NOT NULL,
+    name VARCHAR(32) NOT NULL,
+    url TEXT NOT NULL,
+    weight INTEGER NOT NULL CHECK (weight > 0),
+    UNIQUE (link_id, name)
+);
+
+-- Вариант, на который ушёл переход. Имя, а не ссылка на link_variants: статистика
+-- сохраняется после замены вариантов
+ALTER TABLE clicks ADD COLUMN variant VARCHAR(32) NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE clicks DROP COLUMN variant;
+DROP TABLE IF EXISTS link_variants;
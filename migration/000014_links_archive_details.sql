@@ -0,0 +1,46 @@
+-- +migrate Up
+-- Архив истёкших ссылок хранит ссылку целиком: настройки, правила и варианты A/B теста
+-- (варианты — JSON массивом, как их читает сервис), а клики переносятся в links_archive_clicks.
+ALTER TABLE links_archive ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE links_archive ADD COLUMN preview BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE links_archive ADD COLUMN redirect_type SMALLINT NOT NULL DEFAULT 307;
+ALTER TABLE links_archive ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE links_archive ADD COLUMN max_clicks INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE links_archive ADD COLUMN used_clicks INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE links_archive ADD COLUMN geo_rules JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE links_archive ADD COLUMN device_rules JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE links_archive ADD COLUMN variants JSONB;
+ALTER TABLE links_archive ADD COLUMN fallback_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE links_archive ADD COLUMN starts_at TIMESTAMP;
+
+-- Клики архивных ссылок. Без партиций и внешнего ключа: строки только копируются
+-- при архивировании и читаются вручную.
+CREATE TABLE IF NOT EXISTS links_archive_clicks (
+    id BIGINT NOT NULL,
+    link_id INTEGER NOT NULL,
+    ip_address INET,
+    user_agent TEXT,
+    referer TEXT,
+    country VARCHAR(2),
+    rule TEXT NOT NULL DEFAULT '',
+    variant VARCHAR(32) NOT NULL DEFAULT '',
+    clicked_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (id, clicked_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_links_archive_clicks_link_id ON links_archive_clicks(link_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS links_archive_clicks;
+
+ALTER TABLE links_archive DROP COLUMN starts_at;
+ALTER TABLE links_archive DROP COLUMN fallback_url;
+ALTER TABLE links_archive DROP COLUMN variants;
+ALTER TABLE links_archive DROP COLUMN device_rules;
+ALTER TABLE links_archive DROP COLUMN geo_rules;
+ALTER TABLE links_archive DROP COLUMN used_clicks;
+ALTER TABLE links_archive DROP COLUMN max_clicks;
+ALTER TABLE links_archive DROP COLUMN password_hash;
+ALTER TABLE links_archive DROP COLUMN redirect_type;
+ALTER TABLE links_archive DROP COLUMN preview;
+ALTER TABLE links_archive DROP COLUMN title;
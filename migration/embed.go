@@ -0,0 +1,9 @@
+// Package migration содержит SQL миграции схемы БД, встроенные в бинарник
+package migration
+
+import "embed"
+
+// FS файлы миграций вида 000001_name.sql с секциями "-- +migrate Up" и "-- +migrate Down"
+//
+//go:embed *.sql
+var FS embed.FS